@@ -0,0 +1,314 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const defaultAlterTimeout = 5 * time.Minute
+
+func (c *Client) RenameTable(name string, newName string) error {
+	if c == nil || c.db == nil {
+		return errors.New("db client is nil")
+	}
+	if name == "" || newName == "" {
+		return errors.New("table name is empty")
+	}
+
+	names := c.schemas()
+	schema, _ := names.split(name)
+	newSchema, newTable := names.split(newName)
+	if newSchema != "" && !strings.EqualFold(newSchema, schema) {
+		return fmt.Errorf("rename table %q: can not move it to schema %q", name, newSchema)
+	}
+
+	query := fmt.Sprintf("ALTER TABLE %s RENAME TO %s", names.escape(name), escapeIdentifier(newTable))
+	if err := c.Execute(query); err != nil {
+		return fmt.Errorf("rename table %q: %w", name, err)
+	}
+
+	if schema != "" {
+		newTable = schema + "." + newTable
+	}
+	c.renameRetention(name, newTable, "", "")
+	return nil
+}
+
+func (c *Client) AddColumn(table string, column Column) error {
+	if c == nil || c.db == nil {
+		return errors.New("db client is nil")
+	}
+	if err := validateAlterColumn(table, column); err != nil {
+		return err
+	}
+
+	err := c.alterWithHistory(table, func() error {
+		if column.addableInPlace() {
+			return c.Execute(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", c.schemas().escape(table), buildColumnSQL(column)))
+		}
+
+		return c.rebuildTable(table, func(definitions []string) ([]string, error) {
+			last := -1
+			for i, definition := range definitions {
+				name, ok := definitionColumn(definition)
+				if !ok {
+					continue
+				}
+				if strings.EqualFold(name, column.Name) {
+					return nil, fmt.Errorf("column %q already exists", column.Name)
+				}
+				last = i
+			}
+
+			// Columns go before the table constraints
+			out := append([]string{}, definitions[:last+1]...)
+			out = append(out, buildColumnSQL(column))
+			return append(out, definitions[last+1:]...), nil
+		})
+	}, func(history string) error {
+		return c.addHistoryColumn(history, column)
+	})
+	if err != nil {
+		return fmt.Errorf("add column %q to %q: %w", column.Name, table, err)
+	}
+	return nil
+}
+
+func (c *Client) RenameColumn(table string, column string, newName string) error {
+	if c == nil || c.db == nil {
+		return errors.New("db client is nil")
+	}
+	if table == "" {
+		return errors.New("table name is empty")
+	}
+	if column == "" || newName == "" {
+		return errors.New("column name is empty")
+	}
+
+	query := fmt.Sprintf(
+		"ALTER TABLE %s RENAME COLUMN %s TO %s",
+		c.schemas().escape(table), escapeIdentifier(column), escapeIdentifier(newName),
+	)
+	err := c.alterWithHistory(table, func() error {
+		return c.Execute(query)
+	}, func(history string) error {
+		return c.renameHistoryColumn(history, column, newName)
+	})
+	if err != nil {
+		return fmt.Errorf("rename column %q of %q: %w", column, table, err)
+	}
+
+	c.renameRetention(table, table, column, newName)
+	return nil
+}
+
+// Columns that carry their own key or reference need the rebuild, everything else is dropped in place
+func (c *Client) DropColumn(table string, column string) error {
+	if c == nil || c.db == nil {
+		return errors.New("db client is nil")
+	}
+	if table == "" {
+		return errors.New("table name is empty")
+	}
+	if column == "" {
+		return errors.New("column name is empty")
+	}
+
+	statement, err := c.tableSQL(table)
+	if err != nil {
+		return fmt.Errorf("drop column %q of %q: %w", column, table, err)
+	}
+	_, body, _, err := splitCreateTable(statement)
+	if err != nil {
+		return fmt.Errorf("drop column %q of %q: %w", column, table, err)
+	}
+
+	inPlace := true
+	for _, definition := range splitDefinitions(body) {
+		if name, ok := definitionColumn(definition); ok && strings.EqualFold(name, column) {
+			inPlace = !hasKeyword(sqlWords(definition), "PRIMARY", "UNIQUE", "REFERENCES")
+		}
+	}
+
+	// The history table keeps the column for the versions that had it
+	err = c.alterWithHistory(table, func() error {
+		if inPlace {
+			return c.Execute(fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", c.schemas().escape(table), escapeIdentifier(column)))
+		}
+		return c.rebuildTable(table, func(definitions []string) ([]string, error) {
+			return dropColumnDefinition(definitions, column)
+		})
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("drop column %q of %q: %w", column, table, err)
+	}
+
+	c.renameRetention(table, table, column, "")
+	return nil
+}
+
+// Replaces the definition of the column with the same name, the data is converted by the new column affinity
+func (c *Client) AlterColumn(table string, column Column) error {
+	if c == nil || c.db == nil {
+		return errors.New("db client is nil")
+	}
+	if err := validateAlterColumn(table, column); err != nil {
+		return err
+	}
+
+	err := c.alterWithHistory(table, func() error {
+		return c.rebuildTable(table, func(definitions []string) ([]string, error) {
+			for i, definition := range definitions {
+				if name, ok := definitionColumn(definition); ok && strings.EqualFold(name, column.Name) {
+					out := append([]string{}, definitions...)
+					out[i] = buildColumnSQL(column)
+					return out, nil
+				}
+			}
+			return nil, fmt.Errorf("column %q not found", column.Name)
+		})
+	}, func(history string) error {
+		return c.alterHistoryColumn(history, column)
+	})
+	if err != nil {
+		return fmt.Errorf("alter column %q of %q: %w", column.Name, table, err)
+	}
+	return nil
+}
+
+func (c *Client) AddForeignKey(table string, fk ForeignKey) error {
+	if c == nil || c.db == nil {
+		return errors.New("db client is nil")
+	}
+	if table == "" {
+		return errors.New("table name is empty")
+	}
+	if err := validateAlterForeignKey(fk); err != nil {
+		return fmt.Errorf("add foreign key to %q: %w", table, err)
+	}
+
+	err := c.rebuildTable(table, func(definitions []string) ([]string, error) {
+		columns := map[string]bool{}
+		for _, definition := range definitions {
+			if name, ok := definitionColumn(definition); ok {
+				columns[strings.ToLower(name)] = true
+			}
+		}
+		for _, column := range fk.Columns {
+			if !columns[strings.ToLower(column)] {
+				return nil, fmt.Errorf("foreign key references unknown column %q", column)
+			}
+		}
+		return append(definitions, buildForeignKeysSQL(Table{ForeignKeys: []ForeignKey{fk}})...), nil
+	})
+	if err != nil {
+		return fmt.Errorf("add foreign key to %q: %w", table, err)
+	}
+	return nil
+}
+
+func (c *Client) tableSQL(table string) (string, error) {
+	schema, name := c.schemas().split(table)
+	prefix := ""
+	if schema != "" {
+		prefix = escapeIdentifier(schema) + "."
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rows, err := c.queryLocked(ctx, "SELECT sql FROM "+prefix+"sqlite_master WHERE type = 'table' AND name = ?", name)
+	if err != nil {
+		return "", err
+	}
+	if len(rows) == 0 {
+		return "", fmt.Errorf("table %q not found", table)
+	}
+	return fmt.Sprint(rows[0]["sql"]), nil
+}
+
+func (col Column) addableInPlace() bool {
+	if col.PrimaryKey != nil && *col.PrimaryKey {
+		return false
+	}
+	if col.Unique != nil && *col.Unique {
+		return false
+	}
+	if col.GeneratedExpr != nil && col.Stored != nil && *col.Stored {
+		return false
+	}
+
+	// ADD COLUMN needs a constant default, and a non-null one for NOT NULL columns
+	def := ""
+	if col.Default != nil {
+		def = strings.ToUpper(strings.TrimSpace(*col.Default))
+	}
+	if col.NotNull != nil && *col.NotNull && col.GeneratedExpr == nil && (def == "" || def == "NULL") {
+		return false
+	}
+	return !strings.HasPrefix(def, "(") && !strings.HasPrefix(def, "CURRENT_")
+}
+
+func buildColumnSQL(col Column) string {
+	return buildColumnsSQL(Table{Columns: []Column{col}})[0]
+}
+
+func dropColumnDefinition(definitions []string, column string) ([]string, error) {
+	var out []string
+	found := false
+	for _, definition := range definitions {
+		if name, ok := definitionColumn(definition); ok {
+			if strings.EqualFold(name, column) {
+				found = true
+				continue
+			}
+			out = append(out, definition)
+			continue
+		}
+
+		for _, word := range sqlWords(definition) {
+			if strings.EqualFold(word.text, column) {
+				return nil, fmt.Errorf("column %q is used by table constraint %s", column, definition)
+			}
+		}
+		out = append(out, definition)
+	}
+
+	if !found {
+		return nil, fmt.Errorf("column %q not found", column)
+	}
+	return out, nil
+}
+
+func validateAlterColumn(table string, column Column) error {
+	if table == "" {
+		return errors.New("table name is empty")
+	}
+	if err := (Table{Name: table, Columns: []Column{column}}).Validate(); err != nil {
+		return fmt.Errorf("invalid column %q: %w", column.Name, err)
+	}
+	return nil
+}
+
+func validateAlterForeignKey(fk ForeignKey) error {
+	var errs []error
+	if len(fk.Columns) == 0 {
+		errs = append(errs, errors.New("foreign key has no columns"))
+	}
+	if strings.TrimSpace(fk.ReferenceTable) == "" {
+		errs = append(errs, errors.New("foreign key reference table is empty"))
+	}
+	if len(fk.ReferenceColumns) != len(fk.Columns) {
+		errs = append(errs, fmt.Errorf("foreign key has %d columns and %d reference columns", len(fk.Columns), len(fk.ReferenceColumns)))
+	}
+	if err := validateForeignKeyAction(fk.OnDelete); err != nil {
+		errs = append(errs, fmt.Errorf("ON DELETE: %w", err))
+	}
+	if err := validateForeignKeyAction(fk.OnUpdate); err != nil {
+		errs = append(errs, fmt.Errorf("ON UPDATE: %w", err))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,97 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+func (c *Client) Attach(alias string, otherDbName string, readOnly bool) error {
+	if c == nil || c.db == nil || c.conn == nil {
+		return errors.New("db client is nil")
+	}
+	if alias == "" {
+		return errors.New("attach alias is empty")
+	}
+	if otherDbName == "" {
+		return errors.New("db name is empty")
+	}
+	switch strings.ToLower(alias) {
+	case "main", "temp":
+		return fmt.Errorf("attach alias %q is reserved", alias)
+	}
+
+	dbFile := getDbFile(otherDbName)
+	if readOnly {
+		if _, err := os.Stat(dbFile); err != nil {
+			return fmt.Errorf("attach %q read-only: %w", otherDbName, err)
+		}
+	}
+
+	if c.mutex != nil {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+	}
+
+	previous, replaced := c.conn.removeAttachment(alias)
+	c.conn.setAttachment(attachment{alias: alias, path: dbFile, readOnly: readOnly})
+
+	if err := c.applyAttachments(); err != nil {
+		c.conn.removeAttachment(alias)
+		if replaced {
+			c.conn.setAttachment(previous)
+		}
+		return fmt.Errorf("attach %q as %q: %w", otherDbName, alias, err)
+	}
+
+	return nil
+}
+
+func (c *Client) Detach(alias string) error {
+	if c == nil || c.db == nil || c.conn == nil {
+		return errors.New("db client is nil")
+	}
+	if alias == "" {
+		return errors.New("attach alias is empty")
+	}
+
+	if c.mutex != nil {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+	}
+
+	if _, ok := c.conn.removeAttachment(alias); !ok {
+		return fmt.Errorf("database %q is not attached", alias)
+	}
+
+	if err := c.applyAttachments(); err != nil {
+		return fmt.Errorf("detach %q: %w", alias, err)
+	}
+
+	return nil
+}
+
+func (c *Client) applyAttachments() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Cached results may name the schema that was replaced
+	c.InvalidateCache()
+	return c.db.PingContext(ctx)
+}
+
+// The text before the first dot is a schema only when it is main, temp or an alias attached to this client
+func (c *Client) QuoteName(name string) string {
+	return c.schemas().escape(name)
+}
+
+// Names are qualified against this client's own attachments
+func (c *Client) schemas() schemaNames {
+	if c == nil || c.conn == nil {
+		return nil
+	}
+	return c.conn.schemaNames()
+}
@@ -0,0 +1,33 @@
+package sqlite_test
+
+import (
+	"testing"
+
+	"github.com/halushko/core-go/sqlite"
+	"github.com/halushko/core-go/sqlite/sqlitetest"
+)
+
+func TestQualifiedNamesFollowOwnAttachments(t *testing.T) {
+	t.Setenv("DB_PATH", t.TempDir())
+	notes := sqlite.Table{
+		Name:    "aux.notes",
+		Columns: []sqlite.Column{{Name: "id", Type: sqlite.TypeInteger, PrimaryKey: boolPtr(true)}},
+	}
+
+	attached := sqlitetest.New(t)
+	if err := attached.Attach("aux", "other", false); err != nil {
+		t.Fatalf("attach: %v", err)
+	}
+	other := sqlitetest.New(t)
+
+	for _, client := range []*sqlite.Client{attached, other} {
+		if err := client.CreateTable(notes); err != nil {
+			t.Fatalf("create %s: %v", notes.Name, err)
+		}
+	}
+
+	sqlitetest.AssertRows(t, attached, []map[string]any{{"name": "notes"}}, "SELECT name FROM aux.sqlite_master WHERE type = 'table'")
+	sqlitetest.AssertRows(t, attached, nil, "SELECT name FROM main.sqlite_master WHERE type = 'table'")
+	// Without the alias the dot is part of the table name
+	sqlitetest.AssertRows(t, other, []map[string]any{{"name": "aux.notes"}}, "SELECT name FROM main.sqlite_master WHERE type = 'table'")
+}
@@ -21,13 +21,16 @@ func buildColumnsSQL(t Table) []string {
 			}
 		} else {
 			if c.PrimaryKey != nil && *c.PrimaryKey {
-				part += " PRIMARY KEY"
+				part += " PRIMARY KEY" + buildConflictSQL(c.PrimaryKeyOnConflict)
+				if c.AutoIncrement != nil && *c.AutoIncrement {
+					part += " AUTOINCREMENT"
+				}
 			}
 			if c.NotNull != nil && *c.NotNull {
-				part += " NOT NULL"
+				part += " NOT NULL" + buildConflictSQL(c.NotNullOnConflict)
 			}
 			if c.Unique != nil && *c.Unique {
-				part += " UNIQUE"
+				part += " UNIQUE" + buildConflictSQL(c.UniqueOnConflict)
 			}
 			if c.Default != nil && *c.Default != "" {
 				part += " DEFAULT " + *c.Default
@@ -5,14 +5,16 @@ import (
 	"strings"
 )
 
-func buildIndexesSQL(t Table, ifNotExists bool) []string {
+func buildIndexesSQL(t Table, names schemaNames, ifNotExists bool) []string {
 	var parts []string
 	if t.Name == "" {
 		return []string{}
 	}
+	schema, table := names.split(t.Name)
 
 	for _, idx := range t.Indexes {
-		if idx.Name == "" || len(idx.Columns) == 0 {
+		keys := buildIndexKeysSQL(idx)
+		if idx.Name == "" || keys == "" {
 			continue
 		}
 
@@ -26,15 +28,20 @@ func buildIndexesSQL(t Table, ifNotExists bool) []string {
 
 		where := ""
 		if strings.TrimSpace(idx.Where) != "" {
-			where += "WHERE " + idx.Where
+			where += " WHERE " + idx.Where
+		}
+
+		name := escapeIdentifier(idx.Name)
+		if schema != "" {
+			name = escapeIdentifier(schema) + "." + name
 		}
 
 		part := fmt.Sprintf(
-			"%s %s ON %s (%s) %s;",
+			"%s %s ON %s (%s)%s;",
 			createClause,
-			escapeIdentifier(idx.Name),
-			escapeIdentifier(t.Name),
-			joinEscapedIdentifiers(idx.Columns),
+			name,
+			escapeIdentifier(table),
+			keys,
 			where,
 		)
 
@@ -43,3 +50,25 @@ func buildIndexesSQL(t Table, ifNotExists bool) []string {
 
 	return parts
 }
+
+func buildIndexKeysSQL(idx Index) string {
+	if len(idx.Keys) == 0 {
+		return joinEscapedIdentifiers(idx.Columns)
+	}
+
+	keys := make([]string, 0, len(idx.Keys))
+	for _, key := range idx.Keys {
+		part := escapeIdentifier(key.Column)
+		if strings.TrimSpace(key.Expr) != "" {
+			part = "(" + strings.TrimSpace(key.Expr) + ")"
+		}
+		if key.Collate != "" {
+			part += " COLLATE " + key.Collate
+		}
+		if key.Desc {
+			part += " DESC"
+		}
+		keys = append(keys, part)
+	}
+	return strings.Join(keys, ", ")
+}
@@ -0,0 +1,27 @@
+package sqlite
+
+import (
+	"fmt"
+)
+
+func buildPrimaryKeySQL(t Table) []string {
+	if t.PrimaryKey == nil || len(t.PrimaryKey.Columns) == 0 {
+		return nil
+	}
+
+	part := ""
+	if t.PrimaryKey.Name != "" {
+		part += fmt.Sprintf("CONSTRAINT %s ", escapeIdentifier(t.PrimaryKey.Name))
+	}
+	part += fmt.Sprintf("PRIMARY KEY (%s)", joinEscapedIdentifiers(t.PrimaryKey.Columns))
+	part += buildConflictSQL(t.PrimaryKey.OnConflict)
+
+	return []string{part}
+}
+
+func buildConflictSQL(r ConflictResolution) string {
+	if r == "" {
+		return ""
+	}
+	return " ON CONFLICT " + string(r)
+}
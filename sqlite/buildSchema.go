@@ -0,0 +1,62 @@
+package sqlite
+
+import (
+	"strings"
+)
+
+func (t Table) SQL(opts SQLOptions) string {
+	return joinStatements(t.statements(nil, opts))
+}
+
+func (s Schema) SQL() string {
+	// Triggers follow their table, as in Client.SchemaSQL, so the two can be diffed
+	tableTriggers := map[string][]string{}
+	var viewTriggers []string
+	for _, tr := range s.Triggers {
+		owner := -1
+		for i, t := range s.Tables {
+			if _, table := schemaNames(nil).split(t.Name); table == tr.Table {
+				owner = i
+				break
+			}
+		}
+		if owner < 0 {
+			viewTriggers = append(viewTriggers, buildTriggerSQL(tr, nil, false))
+			continue
+		}
+		tableTriggers[s.Tables[owner].Name] = append(tableTriggers[s.Tables[owner].Name], buildTriggerSQL(tr, nil, false))
+	}
+
+	var statements []string
+	for _, t := range s.Tables {
+		statements = append(statements, t.statements(nil, SQLOptions{})...)
+		statements = append(statements, tableTriggers[t.Name]...)
+	}
+	for _, v := range s.Views {
+		statements = append(statements, buildViewSQL(v, nil, false))
+	}
+	statements = append(statements, viewTriggers...)
+	return joinStatements(statements)
+}
+
+func (t Table) statements(names schemaNames, opts SQLOptions) []string {
+	t = withAuditColumns(t)
+
+	var statements []string
+	if table := buildCreateTableSQL(t, names, opts.IfNotExists); table != "" {
+		statements = append(statements, table)
+	}
+	statements = append(statements, buildIndexesSQL(t, names, opts.IfNotExists)...)
+	statements = append(statements, buildTriggersSQL(t, names, opts.IfNotExists)...)
+	if t.History != nil {
+		statements = append(statements, historyTable(t, names).statements(names, opts)...)
+	}
+	return statements
+}
+
+func joinStatements(statements []string) string {
+	if len(statements) == 0 {
+		return ""
+	}
+	return strings.Join(statements, "\n\n") + "\n"
+}
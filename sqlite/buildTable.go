@@ -5,7 +5,7 @@ import (
 	"strings"
 )
 
-func buildCreateTableSQL(t Table, ifNotExists bool) string {
+func buildCreateTableSQL(t Table, names schemaNames, ifNotExists bool) string {
 	if t.Columns == nil || len(t.Columns) == 0 {
 		return ""
 	}
@@ -15,18 +15,20 @@ func buildCreateTableSQL(t Table, ifNotExists bool) string {
 	}
 
 	columns := buildColumnsSQL(t)
+	primaryKey := buildPrimaryKeySQL(t)
 	uniqueConstraints := buildUniqueConstraintsSQL(t)
 	checks := buildChecksSQL(t)
 	foreignKeys := buildForeignKeysSQL(t)
 
-	parts := append(columns, uniqueConstraints...)
+	parts := append(columns, primaryKey...)
+	parts = append(parts, uniqueConstraints...)
 	parts = append(parts, checks...)
 	parts = append(parts, foreignKeys...)
 
 	return fmt.Sprintf(
 		"%s %s\n(\n    %s\n);",
 		createClause,
-		escapeIdentifier(t.Name),
+		names.escape(t.Name),
 		strings.Join(parts, ",\n    "),
 	)
 }
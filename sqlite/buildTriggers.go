@@ -0,0 +1,157 @@
+package sqlite
+
+import (
+	"fmt"
+	"strings"
+)
+
+func withAuditColumns(t Table) Table {
+	timestamps := t.Timestamps != nil && *t.Timestamps
+	softDelete := t.SoftDelete != nil && *t.SoftDelete
+	if !timestamps && !softDelete {
+		return t
+	}
+
+	existing := make(map[string]bool, len(t.Columns))
+	for _, c := range t.Columns {
+		existing[c.Name] = true
+	}
+
+	columns := append([]Column{}, t.Columns...)
+	if timestamps {
+		for _, name := range []string{ColumnCreatedAt, ColumnUpdatedAt} {
+			if existing[name] {
+				continue
+			}
+			columns = append(columns, Column{
+				Name:    name,
+				Type:    TypeDatetime,
+				NotNull: boolPtr(true),
+				Default: stringPtr("CURRENT_TIMESTAMP"),
+			})
+		}
+	}
+	if softDelete && !existing[ColumnDeletedAt] {
+		columns = append(columns, Column{Name: ColumnDeletedAt, Type: TypeDatetime})
+	}
+
+	t.Columns = columns
+	return t
+}
+
+func auditTriggers(t Table, names schemaNames) []Trigger {
+	var triggers []Trigger
+	if t.Name == "" {
+		return triggers
+	}
+
+	_, table := names.split(t.Name)
+	triggerName := triggerNamer(t.Name, names)
+
+	if t.Timestamps != nil && *t.Timestamps {
+		updatedAt := escapeIdentifier(ColumnUpdatedAt)
+		triggers = append(triggers, Trigger{
+			Name:   triggerName("touch_" + ColumnUpdatedAt),
+			Table:  table,
+			Timing: "AFTER",
+			Event:  "UPDATE",
+			When:   fmt.Sprintf("NEW.%s IS OLD.%s AND OLD.%s IS NOT CURRENT_TIMESTAMP", updatedAt, updatedAt, updatedAt),
+			Body:   fmt.Sprintf("UPDATE %s SET %s = CURRENT_TIMESTAMP WHERE rowid = NEW.rowid;", escapeIdentifier(table), updatedAt),
+		})
+	}
+
+	if t.SoftDelete != nil && *t.SoftDelete {
+		deletedAt := escapeIdentifier(ColumnDeletedAt)
+		triggers = append(triggers, Trigger{
+			Name:   triggerName("soft_delete"),
+			Table:  table,
+			Timing: "BEFORE",
+			Event:  "DELETE",
+			When:   fmt.Sprintf("OLD.%s IS NULL", deletedAt),
+			Body: fmt.Sprintf(
+				"UPDATE %s SET %s = CURRENT_TIMESTAMP WHERE rowid = OLD.rowid;\nSELECT RAISE(IGNORE);",
+				escapeIdentifier(table),
+				deletedAt,
+			),
+		})
+	}
+
+	if t.History != nil {
+		triggers = append(triggers, historyTriggers(t, names, triggerName)...)
+	}
+
+	return triggers
+}
+
+// Trigger names are prefixed with the table and live in its schema
+func triggerNamer(name string, names schemaNames) func(suffix string) string {
+	schema, table := names.split(name)
+	return func(suffix string) string {
+		if schema != "" {
+			return schema + "." + table + "_" + suffix
+		}
+		return table + "_" + suffix
+	}
+}
+
+func buildTriggersSQL(t Table, names schemaNames, ifNotExists bool) []string {
+	var parts []string
+	for _, trigger := range auditTriggers(t, names) {
+		parts = append(parts, buildTriggerSQL(trigger, names, ifNotExists))
+	}
+	return parts
+}
+
+func buildTriggerSQL(tr Trigger, names schemaNames, ifNotExists bool) string {
+	createClause := "CREATE TRIGGER"
+	if ifNotExists {
+		createClause += " IF NOT EXISTS"
+	}
+
+	timing := strings.ToUpper(strings.TrimSpace(tr.Timing))
+	if timing != "" {
+		timing += " "
+	}
+
+	event := strings.ToUpper(strings.TrimSpace(tr.Event))
+	if event == "UPDATE" && len(tr.Columns) > 0 {
+		event += " OF " + joinEscapedIdentifiers(tr.Columns)
+	}
+
+	when := ""
+	if strings.TrimSpace(tr.When) != "" {
+		when = " WHEN " + strings.TrimSpace(tr.When)
+	}
+
+	var body []string
+	for _, line := range strings.Split(strings.TrimSpace(tr.Body), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		body = append(body, "    "+strings.TrimSpace(line))
+	}
+	if len(body) > 0 && !strings.HasSuffix(body[len(body)-1], ";") {
+		body[len(body)-1] += ";"
+	}
+
+	return fmt.Sprintf(
+		"%s %s\n%s%s ON %s\nFOR EACH ROW%s\nBEGIN\n%s\nEND;",
+		createClause,
+		names.escape(tr.Name),
+		timing,
+		event,
+		escapeIdentifier(tr.Table),
+		when,
+		strings.Join(body, "\n"),
+	)
+}
+
+func buildViewSQL(v View, names schemaNames, ifNotExists bool) string {
+	createClause := "CREATE VIEW"
+	if ifNotExists {
+		createClause += " IF NOT EXISTS"
+	}
+
+	query := strings.TrimRight(strings.TrimSpace(v.Query), ";")
+	return fmt.Sprintf("%s %s AS\n%s;", createClause, names.escape(v.Name), query)
+}
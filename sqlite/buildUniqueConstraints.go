@@ -17,6 +17,7 @@ func buildUniqueConstraintsSQL(t Table) []string {
 			part += fmt.Sprintf("CONSTRAINT %s ", escapeIdentifier(uc.Name))
 		}
 		part += fmt.Sprintf("UNIQUE (%s)", joinEscapedIdentifiers(uc.Columns))
+		part += buildConflictSQL(uc.OnConflict)
 
 		parts = append(parts, part)
 	}
@@ -0,0 +1,317 @@
+package sqlite
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+var schemaStatementRegexp = regexp.MustCompile(
+	`(?i)\b(?:(?:CREATE|DROP|ALTER)\s+(?:(?:TEMP|TEMPORARY|VIRTUAL)\s+)?(?:TABLE|VIEW)|ATTACH|DETACH)\b`,
+)
+
+type CacheOptions struct {
+	TTL        time.Duration
+	MaxEntries int
+}
+
+type CacheStats struct {
+	Hits          int64
+	Misses        int64
+	Evictions     int64
+	Invalidations int64
+	Entries       int
+}
+
+type queryCache struct {
+	options CacheOptions
+
+	mu         sync.Mutex
+	entries    map[string]*list.Element
+	lru        *list.List
+	generation uint64
+	stats      CacheStats
+}
+
+type cacheEntry struct {
+	key     string
+	rows    []map[string]any
+	tables  map[string]struct{}
+	expires time.Time
+}
+
+func (c *Client) EnableCache(opts CacheOptions) error {
+	if c == nil || c.db == nil || c.conn == nil {
+		return errors.New("db client is nil")
+	}
+	if opts.TTL <= 0 {
+		return fmt.Errorf("invalid cache ttl: %s", opts.TTL)
+	}
+	if opts.MaxEntries <= 0 {
+		return fmt.Errorf("invalid cache size: %d", opts.MaxEntries)
+	}
+
+	cache := &queryCache{options: opts, entries: map[string]*list.Element{}, lru: list.New()}
+	if !c.conn.cache.CompareAndSwap(nil, cache) {
+		return errors.New("cache is already enabled")
+	}
+	return nil
+}
+
+func (c *Client) DisableCache() {
+	if c == nil || c.conn == nil {
+		return
+	}
+	c.conn.cache.Store(nil)
+}
+
+func (c *Client) CacheStats() CacheStats {
+	if c == nil || c.conn == nil {
+		return CacheStats{}
+	}
+	cache := c.conn.cache.Load()
+	if cache == nil {
+		return CacheStats{}
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	stats := cache.stats
+	stats.Entries = cache.lru.Len()
+	return stats
+}
+
+func (c *Client) InvalidateCache(tables ...string) {
+	if c == nil || c.conn == nil {
+		return
+	}
+	cache := c.conn.cache.Load()
+	if cache == nil {
+		return
+	}
+	if len(tables) == 0 {
+		cache.invalidateAll()
+		return
+	}
+
+	keys := make(map[string]struct{}, len(tables))
+	for _, table := range tables {
+		schema, name := c.schemas().split(table)
+		if schema == "" {
+			schema = "main"
+		}
+		keys[cacheTableKey(schema, name)] = struct{}{}
+	}
+	cache.invalidate(keys)
+}
+
+func (c *Client) cachedSelect(ctx context.Context, cache *queryCache, query string, args ...any) ([]map[string]any, error) {
+	key := cacheKey(query, args)
+	if rows, ok := cache.get(key); ok {
+		return rows, nil
+	}
+	generation := cache.currentGeneration()
+
+	if threshold := c.scanThreshold.Load(); threshold > 0 {
+		c.warnFullScans(ctx, threshold, query, args...)
+	}
+
+	rows, err := c.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("select query: %w", err)
+	}
+	out, err := scanRows(rows)
+	_ = rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	// Queries that write or read virtual tables are never cached, and neither are the ones
+	// reading no table at all such as PRAGMA or sqlite_master, as no write would invalidate them
+	if tables, ok := c.queryTables(ctx, query, args...); ok && len(tables) > 0 {
+		cache.put(key, copyRows(out), tables, generation)
+	}
+	return out, nil
+}
+
+// Resolves the b-trees opened by the compiled statement back to their tables
+func (c *Client) queryTables(ctx context.Context, query string, args ...any) (map[string]struct{}, bool) {
+	program, err := c.db.QueryContext(ctx, "EXPLAIN "+query, args...)
+	if err != nil {
+		return nil, false
+	}
+	opened, err := scanRows(program)
+	_ = program.Close()
+	if err != nil {
+		return nil, false
+	}
+
+	pages := map[int64]map[int64]bool{}
+	for _, op := range opened {
+		switch op["opcode"] {
+		case "OpenRead", "ReopenIdx":
+			database, _ := op["p3"].(int64)
+			page, _ := op["p2"].(int64)
+			if pages[database] == nil {
+				pages[database] = map[int64]bool{}
+			}
+			pages[database][page] = true
+		case "OpenWrite", "VOpen", "VUpdate":
+			return nil, false
+		}
+	}
+
+	databases, err := c.queryRows(ctx, "PRAGMA database_list")
+	if err != nil {
+		return nil, false
+	}
+
+	tables := map[string]struct{}{}
+	for _, database := range databases {
+		seq, _ := database["seq"].(int64)
+		if len(pages[seq]) == 0 {
+			continue
+		}
+		name := fmt.Sprint(database["name"])
+
+		master := escapeIdentifier(name) + ".sqlite_master"
+		if name == "temp" {
+			master = "temp.sqlite_temp_master"
+		}
+		objects, err := c.queryRows(ctx, fmt.Sprintf("SELECT rootpage, tbl_name FROM %s WHERE rootpage > 0", master))
+		if err != nil {
+			return nil, false
+		}
+		for _, object := range objects {
+			if page, _ := object["rootpage"].(int64); pages[seq][page] {
+				tables[cacheTableKey(name, fmt.Sprint(object["tbl_name"]))] = struct{}{}
+			}
+		}
+	}
+
+	return tables, true
+}
+
+func (c *Client) queryRows(ctx context.Context, query string, args ...any) ([]map[string]any, error) {
+	rows, err := c.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanRows(rows)
+}
+
+func (q *queryCache) get(key string) ([]map[string]any, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	element, ok := q.entries[key]
+	if !ok {
+		q.stats.Misses++
+		return nil, false
+	}
+	entry := element.Value.(*cacheEntry)
+	if time.Now().After(entry.expires) {
+		q.remove(element)
+		q.stats.Misses++
+		return nil, false
+	}
+
+	q.lru.MoveToFront(element)
+	q.stats.Hits++
+	return copyRows(entry.rows), true
+}
+
+func (q *queryCache) currentGeneration() uint64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.generation
+}
+
+func (q *queryCache) put(key string, rows []map[string]any, tables map[string]struct{}, generation uint64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	// A write committed while the query ran, the result may already be stale
+	if generation != q.generation {
+		return
+	}
+
+	if element, ok := q.entries[key]; ok {
+		q.remove(element)
+	}
+	entry := &cacheEntry{key: key, rows: rows, tables: tables, expires: time.Now().Add(q.options.TTL)}
+	q.entries[key] = q.lru.PushFront(entry)
+
+	for q.lru.Len() > q.options.MaxEntries {
+		q.remove(q.lru.Back())
+		q.stats.Evictions++
+	}
+}
+
+func (q *queryCache) invalidate(tables map[string]struct{}) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.generation++
+	for element := q.lru.Front(); element != nil; {
+		next := element.Next()
+		for table := range element.Value.(*cacheEntry).tables {
+			if _, ok := tables[table]; ok {
+				q.remove(element)
+				q.stats.Invalidations++
+				break
+			}
+		}
+		element = next
+	}
+}
+
+func (q *queryCache) invalidateAll() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.generation++
+	q.stats.Invalidations += int64(q.lru.Len())
+	q.entries = map[string]*list.Element{}
+	q.lru.Init()
+}
+
+func (q *queryCache) remove(element *list.Element) {
+	delete(q.entries, element.Value.(*cacheEntry).key)
+	q.lru.Remove(element)
+}
+
+func cacheKey(query string, args []any) string {
+	var key strings.Builder
+	key.WriteString(query)
+	for _, arg := range args {
+		_, _ = fmt.Fprintf(&key, "\x00%T:%v", arg, arg)
+	}
+	return key.String()
+}
+
+func cacheTableKey(schema string, table string) string {
+	return strings.ToLower(schema) + "." + strings.ToLower(table)
+}
+
+func copyRows(rows []map[string]any) []map[string]any {
+	out := make([]map[string]any, len(rows))
+	for i, row := range rows {
+		copied := make(map[string]any, len(row))
+		for column, value := range row {
+			if b, ok := value.([]byte); ok {
+				value = append([]byte(nil), b...)
+			}
+			copied[column] = value
+		}
+		out[i] = copied
+	}
+	return out
+}
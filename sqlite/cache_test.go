@@ -0,0 +1,119 @@
+package sqlite_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/halushko/core-go/sqlite"
+	"github.com/halushko/core-go/sqlite/sqlitetest"
+)
+
+var cacheTables = []sqlite.Table{
+	{
+		Name: "notes",
+		Columns: []sqlite.Column{
+			{Name: "id", Type: sqlite.TypeInteger, PrimaryKey: boolPtr(true)},
+			{Name: "body", Type: sqlite.TypeText},
+		},
+	},
+	{
+		Name:    "tags",
+		Columns: []sqlite.Column{{Name: "name", Type: sqlite.TypeText}},
+	},
+}
+
+func newCachedClient(t *testing.T, opts sqlite.CacheOptions) *sqlite.Client {
+	t.Helper()
+
+	client := sqlitetest.New(t, cacheTables...)
+	sqlitetest.Exec(t, client, "INSERT INTO notes (id, body) VALUES (1, 'a')")
+	if err := client.EnableCache(opts); err != nil {
+		t.Fatalf("enable cache: %v", err)
+	}
+	return client
+}
+
+func selectNotes(t *testing.T, client *sqlite.Client) int {
+	t.Helper()
+
+	rows, err := client.ExecSelect("SELECT id, body FROM notes")
+	if err != nil {
+		t.Fatalf("select notes: %v", err)
+	}
+	return len(rows)
+}
+
+func assertCacheStats(t *testing.T, client *sqlite.Client, want sqlite.CacheStats) {
+	t.Helper()
+
+	if got := client.CacheStats(); got != want {
+		t.Errorf("cache stats %+v, want %+v", got, want)
+	}
+}
+
+func TestCacheInvalidatesWrittenTables(t *testing.T) {
+	client := newCachedClient(t, sqlite.CacheOptions{TTL: time.Minute, MaxEntries: 10})
+
+	selectNotes(t, client)
+	selectNotes(t, client)
+	assertCacheStats(t, client, sqlite.CacheStats{Hits: 1, Misses: 1, Entries: 1})
+
+	// Other tables keep the entry
+	sqlitetest.Exec(t, client, "INSERT INTO tags (name) VALUES ('x')")
+	selectNotes(t, client)
+	assertCacheStats(t, client, sqlite.CacheStats{Hits: 2, Misses: 1, Entries: 1})
+
+	sqlitetest.Exec(t, client, "INSERT INTO notes (id, body) VALUES (2, 'b')")
+	if n := selectNotes(t, client); n != 2 {
+		t.Errorf("notes after insert: %d, want 2", n)
+	}
+	assertCacheStats(t, client, sqlite.CacheStats{Hits: 2, Misses: 2, Invalidations: 1, Entries: 1})
+
+	err := client.Transaction(func(tx sqlite.TxDBI) error {
+		return tx.Execute("DELETE FROM notes WHERE id = 2")
+	})
+	if err != nil {
+		t.Fatalf("transaction: %v", err)
+	}
+	if n := selectNotes(t, client); n != 1 {
+		t.Errorf("notes after delete: %d, want 1", n)
+	}
+}
+
+func TestCacheExpiresAfterTTL(t *testing.T) {
+	client := newCachedClient(t, sqlite.CacheOptions{TTL: 20 * time.Millisecond, MaxEntries: 10})
+
+	selectNotes(t, client)
+	time.Sleep(30 * time.Millisecond)
+	selectNotes(t, client)
+	assertCacheStats(t, client, sqlite.CacheStats{Misses: 2, Entries: 1})
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	client := newCachedClient(t, sqlite.CacheOptions{TTL: time.Minute, MaxEntries: 2})
+
+	for _, id := range []int{1, 2, 1, 3, 1, 2} {
+		if _, err := client.ExecSelect("SELECT body FROM notes WHERE id = ?", id); err != nil {
+			t.Fatalf("select %d: %v", id, err)
+		}
+	}
+	// 3 pushes out 2, which is read again and pushes out 3
+	assertCacheStats(t, client, sqlite.CacheStats{Hits: 2, Misses: 4, Evictions: 2, Entries: 2})
+}
+
+func TestCacheHooksFollowEnable(t *testing.T) {
+	client := newCachedClient(t, sqlite.CacheOptions{TTL: time.Minute, MaxEntries: 10})
+
+	client.DisableCache()
+	sqlitetest.Exec(t, client, "DELETE FROM notes")
+	assertCacheStats(t, client, sqlite.CacheStats{})
+
+	if err := client.EnableCache(sqlite.CacheOptions{TTL: time.Minute, MaxEntries: 10}); err != nil {
+		t.Fatalf("enable cache again: %v", err)
+	}
+	selectNotes(t, client)
+	sqlitetest.Exec(t, client, "INSERT INTO notes (id, body) VALUES (1, 'a')")
+	if n := selectNotes(t, client); n != 1 {
+		t.Errorf("notes after insert: %d, want 1", n)
+	}
+}
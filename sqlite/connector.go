@@ -0,0 +1,450 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	engine "modernc.org/sqlite"
+)
+
+var uriPathEscaper = strings.NewReplacer("%", "%25", "?", "%3f", "#", "%23")
+
+type attachment struct {
+	alias    string
+	path     string
+	readOnly bool
+}
+
+type connector struct {
+	base driver.Connector
+
+	mu          sync.RWMutex
+	generation  uint64
+	attachments map[string]attachment
+
+	cache atomic.Pointer[queryCache]
+}
+
+type conn struct {
+	driver.Conn
+	connector  *connector
+	generation uint64
+	attached   map[string]attachment
+
+	hooks         engine.HookRegisterer
+	written       map[string]struct{}
+	schemaChanged bool
+
+	// Set only while the cache is enabled, a pre-update hook turns off the truncate optimization of DELETE
+	hooked bool
+
+	// Committed but not yet reported, readers must see the commit before the cache drops entries
+	committed       map[string]struct{}
+	committedSchema bool
+}
+
+type tx struct {
+	driver.Tx
+	conn *conn
+}
+
+type stmt struct {
+	driver.Stmt
+	conn *conn
+}
+
+type rows struct {
+	driver.Rows
+	conn *conn
+}
+
+func newConnector(dsn string) (*connector, error) {
+	// Only Nearest needs the functions, so a failed registration is reported there instead of failing Open
+	_ = registerFunctions()
+
+	base, err := engine.NewConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	return &connector{base: base, attachments: map[string]attachment{}}, nil
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	raw, err := c.base.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	wrapped := &conn{
+		Conn:      raw,
+		connector: c,
+		attached:  map[string]attachment{},
+		written:   map[string]struct{}{},
+		committed: map[string]struct{}{},
+	}
+	if hooks, ok := raw.(engine.HookRegisterer); ok {
+		wrapped.hooks = hooks
+	}
+	if err := wrapped.sync(ctx); err != nil {
+		_ = raw.Close()
+		return nil, err
+	}
+
+	return wrapped, nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return c.base.Driver()
+}
+
+func (c *connector) setAttachment(a attachment) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.attachments[a.alias] = a
+	c.generation++
+}
+
+func (c *connector) removeAttachment(alias string) (attachment, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	a, ok := c.attachments[alias]
+	if !ok {
+		return attachment{}, false
+	}
+	delete(c.attachments, alias)
+	c.generation++
+
+	return a, true
+}
+
+func (c *connector) snapshot() (uint64, map[string]attachment) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	out := make(map[string]attachment, len(c.attachments))
+	for alias, a := range c.attachments {
+		out[alias] = a
+	}
+	return c.generation, out
+}
+
+func (c *connector) schemaNames() schemaNames {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	names := make(schemaNames, len(c.attachments))
+	for alias := range c.attachments {
+		names[strings.ToLower(alias)] = true
+	}
+	return names
+}
+
+func (c *conn) sync(ctx context.Context) error {
+	generation, wanted := c.connector.snapshot()
+	if generation == c.generation {
+		return nil
+	}
+
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return fmt.Errorf("sqlite connection does not support ExecContext")
+	}
+
+	for _, alias := range sortedAliases(c.attached) {
+		if current, keep := wanted[alias]; keep && current == c.attached[alias] {
+			continue
+		}
+		query := fmt.Sprintf("DETACH DATABASE %s", escapeIdentifier(alias))
+		if _, err := execer.ExecContext(ctx, query, nil); err != nil {
+			return fmt.Errorf("detach %q: %w", alias, err)
+		}
+		delete(c.attached, alias)
+	}
+
+	for _, alias := range sortedAliases(wanted) {
+		if _, done := c.attached[alias]; done {
+			continue
+		}
+		a := wanted[alias]
+		query := fmt.Sprintf("ATTACH DATABASE ? AS %s", escapeIdentifier(alias))
+		args := []driver.NamedValue{{Ordinal: 1, Value: a.uri()}}
+		if _, err := execer.ExecContext(ctx, query, args); err != nil {
+			return fmt.Errorf("attach %q: %w", alias, err)
+		}
+		c.attached[alias] = a
+	}
+
+	c.generation = generation
+	return nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	c.noteStatement(query)
+	defer c.reportChanges()
+	return execer.ExecContext(ctx, query, args)
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	c.noteStatement(query)
+	result, err := queryer.QueryContext(ctx, query, args)
+	if err != nil {
+		c.reportChanges()
+		return nil, err
+	}
+	return &rows{Rows: result, conn: c}, nil
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	c.noteStatement(query)
+	var prepared driver.Stmt
+	var err error
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		prepared, err = preparer.PrepareContext(ctx, query)
+	} else {
+		prepared, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &stmt{Stmt: prepared, conn: c}, nil
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	var begun driver.Tx
+	var err error
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		begun, err = beginner.BeginTx(ctx, opts)
+	} else {
+		begun, err = c.Conn.Begin()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tx{Tx: begun, conn: c}, nil
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *conn) ResetSession(ctx context.Context) error {
+	c.reportChanges()
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		if err := resetter.ResetSession(ctx); err != nil {
+			return err
+		}
+	}
+	if err := c.sync(ctx); err != nil {
+		return driver.ErrBadConn
+	}
+	return nil
+}
+
+func (c *conn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+func (c *conn) Close() error {
+	if c.hooked {
+		c.unregisterHooks()
+	}
+	return c.Conn.Close()
+}
+
+// Follows the cache being enabled or disabled, called before each statement runs
+func (c *conn) syncHooks() {
+	enabled := c.connector.cache.Load() != nil
+	if c.hooks == nil || enabled == c.hooked {
+		return
+	}
+	if enabled {
+		c.registerHooks()
+		return
+	}
+	c.unregisterHooks()
+	c.resetChanges()
+	clear(c.committed)
+	c.committedSchema = false
+}
+
+// Row changes are collected per connection and reported to the cache once committed
+func (c *conn) registerHooks() {
+	c.hooked = true
+
+	c.hooks.RegisterPreUpdateHook(func(data engine.SQLitePreUpdateData) {
+		c.written[cacheTableKey(data.DatabaseName, data.TableName)] = struct{}{}
+	})
+	// The hook runs before the commit is visible, so the changes are only reported once it returns
+	c.hooks.RegisterCommitHook(func() int32 {
+		c.committedSchema = c.committedSchema || c.schemaChanged
+		for table := range c.written {
+			c.committed[table] = struct{}{}
+		}
+		c.resetChanges()
+		return 0
+	})
+	c.hooks.RegisterRollbackHook(c.resetChanges)
+}
+
+func (c *conn) unregisterHooks() {
+	c.hooked = false
+
+	c.hooks.RegisterPreUpdateHook(nil)
+	c.hooks.RegisterCommitHook(nil)
+	c.hooks.RegisterRollbackHook(nil)
+}
+
+func (c *conn) reportChanges() {
+	if !c.committedSchema && len(c.committed) == 0 {
+		return
+	}
+	if cache := c.connector.cache.Load(); cache != nil {
+		if c.committedSchema {
+			cache.invalidateAll()
+		} else {
+			cache.invalidate(c.committed)
+		}
+	}
+	clear(c.committed)
+	c.committedSchema = false
+}
+
+func (c *conn) noteStatement(query string) {
+	c.syncHooks()
+	if c.hooked && schemaStatementRegexp.MatchString(query) {
+		c.schemaChanged = true
+	}
+}
+
+func (c *conn) resetChanges() {
+	clear(c.written)
+	c.schemaChanged = false
+}
+
+func (t *tx) Commit() error {
+	defer t.conn.reportChanges()
+	return t.Tx.Commit()
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	s.conn.syncHooks()
+	defer s.conn.reportChanges()
+	if execer, ok := s.Stmt.(driver.StmtExecContext); ok {
+		return execer.ExecContext(ctx, args)
+	}
+	values, err := namedValues(args)
+	if err != nil {
+		return nil, err
+	}
+	return s.Stmt.Exec(values)
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	s.conn.syncHooks()
+	var result driver.Rows
+	var err error
+	if queryer, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		result, err = queryer.QueryContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedValues(args); err == nil {
+			result, err = s.Stmt.Query(values)
+		}
+	}
+	if err != nil {
+		s.conn.reportChanges()
+		return nil, err
+	}
+	return &rows{Rows: result, conn: s.conn}, nil
+}
+
+// An autocommit statement with RETURNING commits when its rows are closed
+func (r *rows) Close() error {
+	defer r.conn.reportChanges()
+	return r.Rows.Close()
+}
+
+func (r *rows) ColumnTypeDatabaseTypeName(index int) string {
+	if typed, ok := r.Rows.(driver.RowsColumnTypeDatabaseTypeName); ok {
+		return typed.ColumnTypeDatabaseTypeName(index)
+	}
+	return ""
+}
+
+func (r *rows) ColumnTypeScanType(index int) reflect.Type {
+	if typed, ok := r.Rows.(driver.RowsColumnTypeScanType); ok {
+		return typed.ColumnTypeScanType(index)
+	}
+	return reflect.TypeFor[any]()
+}
+
+func (r *rows) ColumnTypeLength(index int) (int64, bool) {
+	if typed, ok := r.Rows.(driver.RowsColumnTypeLength); ok {
+		return typed.ColumnTypeLength(index)
+	}
+	return 0, false
+}
+
+func (r *rows) ColumnTypeNullable(index int) (bool, bool) {
+	if typed, ok := r.Rows.(driver.RowsColumnTypeNullable); ok {
+		return typed.ColumnTypeNullable(index)
+	}
+	return false, false
+}
+
+func (r *rows) ColumnTypePrecisionScale(index int) (int64, int64, bool) {
+	if typed, ok := r.Rows.(driver.RowsColumnTypePrecisionScale); ok {
+		return typed.ColumnTypePrecisionScale(index)
+	}
+	return 0, 0, false
+}
+
+func namedValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, fmt.Errorf("named argument %q is not supported", arg.Name)
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
+
+func (a attachment) uri() string {
+	if !a.readOnly {
+		return a.path
+	}
+	return "file:" + uriPathEscaper.Replace(a.path) + "?mode=ro"
+}
+
+func sortedAliases(m map[string]attachment) []string {
+	aliases := make([]string, 0, len(m))
+	for alias := range m {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	return aliases
+}
@@ -5,19 +5,18 @@ import (
 	external "database/sql"
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
-
-	_ "modernc.org/sqlite"
 )
 
-type DBI interface {
-	Open(name string) (*Client, error)
-	OpenMutex(name string, mutex *sync.Mutex) (*Client, error)
+var _ DBI = (*Client)(nil)
 
-	Select(query string, args ...any) ([]map[string]any, error)
+// The core every client and fake implements, features have their own interfaces in interfaces.go
+type DBI interface {
 	Execute(query string, args ...any) error
 	ExecuteNamed(query string, params map[string]any) error
 	ExecuteSqlFile(path string, args ...any) error
@@ -25,11 +24,18 @@ type DBI interface {
 	ExecSelect(query string, args ...any) ([]map[string]any, error)
 	ExecSelectNamed(query string, params map[string]any) ([]map[string]any, error)
 	ExecSelectWithTimeout(query string, timeout time.Duration, args ...any) ([]map[string]any, error)
+	ExecSelectWithTimeoutNamed(query string, timeout time.Duration, params map[string]any) ([]map[string]any, error)
 	ExecSelectSqlFile(path string, args ...any) ([]map[string]any, error)
+	ExecSelectSqlFileNamed(path string, params map[string]any) ([]map[string]any, error)
 	ExecSelectSqlFileWithTimeout(path string, timeout time.Duration, args ...any) ([]map[string]any, error)
+	ExecSelectSqlFileWithTimeoutNamed(path string, timeout time.Duration, params map[string]any) ([]map[string]any, error)
+
+	Transaction(fn func(tx TxDBI) error) error
+	TransactionWithTimeout(timeout time.Duration, fn func(tx TxDBI) error) error
 
 	CreateTable(t Table) error
 	DropTable(name string) error
+	TruncateTable(name string) error
 
 	Close() error
 }
@@ -42,6 +48,31 @@ func OpenMutex(name string) (*Client, error) {
 	return open(name, &sync.Mutex{})
 }
 
+func OpenMemory(name string) (*Client, error) {
+	if name == "" {
+		return nil, errors.New("db name is empty")
+	}
+
+	dsn := "file:" + url.PathEscape(name) + "?mode=memory&cache=shared"
+	client, err := openDSN(dsn, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// A shared-cache memory database lives only while a connection is open
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	keeper, err := client.db.Conn(ctx)
+	if err != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("hold memory db: %w", err)
+	}
+	client.keeper = keeper
+
+	return client, nil
+}
+
 func open(name string, mutex *sync.Mutex) (*Client, error) {
 	if name == "" {
 		return nil, errors.New("db name is empty")
@@ -51,12 +82,15 @@ func open(name string, mutex *sync.Mutex) (*Client, error) {
 		return nil, fmt.Errorf("mkdir db path: %w", err)
 	}
 
-	dbFile := filepath.Join(getDbPath(), name+".sqlite")
+	return openDSN(getDbFile(name), mutex)
+}
 
-	db, err := external.Open("sqlite", dbFile)
+func openDSN(dsn string, mutex *sync.Mutex) (*Client, error) {
+	conn, err := newConnector(dsn)
 	if err != nil {
 		return nil, fmt.Errorf("sql open: %w", err)
 	}
+	db := external.OpenDB(conn)
 
 	// Fail fast
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -67,13 +101,17 @@ func open(name string, mutex *sync.Mutex) (*Client, error) {
 		return nil, fmt.Errorf("db ping: %w", err)
 	}
 
-	return &Client{db: db, mutex: mutex}, nil
+	return &Client{db: db, conn: conn, mutex: mutex}, nil
 }
 
 func (c *Client) Close() error {
 	if c == nil || c.db == nil {
 		return nil
 	}
+	c.StopMaintenance()
+	if c.keeper != nil {
+		_ = c.keeper.Close()
+	}
 	return c.db.Close()
 }
 
@@ -84,16 +122,29 @@ func (c *Client) Execute(query string, args ...any) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	if c.mutex != nil {
-		c.mutex.Lock()
-		defer c.mutex.Unlock()
-	}
+	_, err := c.executeContext(ctx, query, args...)
+	return err
+}
 
-	_, err := c.db.ExecContext(ctx, query, args...)
+func (c *Client) executeContext(ctx context.Context, query string, args ...any) (external.Result, error) {
+	var result external.Result
+	err := c.intercept(ctx, &Query{Kind: QueryExecute, SQL: query, Args: args}, func(ctx context.Context, q *Query) error {
+		if c.mutex != nil {
+			c.mutex.Lock()
+			defer c.mutex.Unlock()
+		}
+
+		var err error
+		if result, err = c.db.ExecContext(ctx, q.SQL, q.Args...); err != nil {
+			return fmt.Errorf("execute: %w", err)
+		}
+		q.Rows, _ = result.RowsAffected()
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("execute: %w", err)
+		return nil, err
 	}
-	return nil
+	return result, nil
 }
 
 func (c *Client) ExecuteNamed(query string, params map[string]any) error {
@@ -155,52 +206,35 @@ func (c *Client) ExecSelectWithTimeout(query string, timeout time.Duration, args
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	if c.mutex != nil {
-		c.mutex.Lock()
-		defer c.mutex.Unlock()
-	}
-
-	rows, err := c.db.QueryContext(ctx, query, args...)
-	if err != nil {
-		return nil, fmt.Errorf("select query: %w", err)
-	}
-	defer rows.Close()
-
-	cols, err := rows.Columns()
+	var out []map[string]any
+	err := c.intercept(ctx, &Query{Kind: QuerySelect, SQL: query, Args: args}, func(ctx context.Context, q *Query) error {
+		var err error
+		out, err = c.execSelect(ctx, q.SQL, q.Args...)
+		q.Rows = int64(len(out))
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("columns: %w", err)
+		return nil, err
 	}
+	return out, nil
+}
 
-	out := make([]map[string]any, 0, 16)
-
-	for rows.Next() {
-		raw := make([]any, len(cols))
-		ptrs := make([]any, len(cols))
-		for i := range raw {
-			ptrs[i] = &raw[i]
-		}
-
-		if err := rows.Scan(ptrs...); err != nil {
-			return nil, fmt.Errorf("scan: %w", err)
-		}
-
-		m := make(map[string]any, len(cols))
-		for i, name := range cols {
-			v := raw[i]
-			if b, ok := v.([]byte); ok {
-				m[name] = string(b)
-			} else {
-				m[name] = v
+func (c *Client) execSelect(ctx context.Context, query string, args ...any) ([]map[string]any, error) {
+	if c.conn != nil {
+		if cache := c.conn.cache.Load(); cache != nil {
+			if c.mutex != nil {
+				c.mutex.Lock()
+				defer c.mutex.Unlock()
 			}
+			return c.cachedSelect(ctx, cache, query, args...)
 		}
-		out = append(out, m)
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("rows err: %w", err)
+	result, err := c.execSelectResult(ctx, query, args...)
+	if err != nil {
+		return nil, err
 	}
-
-	return out, nil
+	return result.Maps(), nil
 }
 
 func (c *Client) ExecSelectWithTimeoutNamed(query string, timeout time.Duration, params map[string]any) ([]map[string]any, error) {
@@ -252,8 +286,13 @@ func (c *Client) CreateTable(t Table) error {
 	if t.Name == "" {
 		return fmt.Errorf("table name is empty")
 	}
+	if err := t.Validate(); err != nil {
+		return fmt.Errorf("invalid table %q: %w", t.Name, err)
+	}
+	t = withAuditColumns(t)
+	names := c.schemas()
 
-	table := buildCreateTableSQL(t, true)
+	table := buildCreateTableSQL(t, names, true)
 	if table != "" {
 		if err := c.Execute(table); err != nil {
 			return fmt.Errorf("create table %q: %w", t.Name, err)
@@ -261,13 +300,35 @@ func (c *Client) CreateTable(t Table) error {
 	}
 
 	if t.Indexes != nil && len(t.Indexes) > 0 {
-		for i, index := range buildIndexesSQL(t, true) {
+		for i, index := range buildIndexesSQL(t, names, true) {
 			if err := c.Execute(index); err != nil {
 				return fmt.Errorf("create index %q: %w", t.Indexes[i].Name, err)
 			}
 		}
 	}
 
+	// The history table must exist before the triggers that write to it
+	if t.History != nil {
+		if err := c.CreateTable(historyTable(t, names)); err != nil {
+			return fmt.Errorf("create history of %q: %w", t.Name, err)
+		}
+		if err := c.backfillHistory(t); err != nil {
+			return err
+		}
+	}
+
+	for _, trigger := range buildTriggersSQL(t, names, true) {
+		if err := c.Execute(trigger); err != nil {
+			return fmt.Errorf("create trigger on %q: %w", t.Name, err)
+		}
+	}
+
+	if t.Retention != nil {
+		if err := c.SetRetention(t.Name, *t.Retention); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -276,7 +337,7 @@ func (c *Client) DropTable(name string) error {
 		return errors.New("table name is empty")
 	}
 
-	query := fmt.Sprintf(`DROP TABLE IF EXISTS %q`, name)
+	query := fmt.Sprintf(`DROP TABLE IF EXISTS %s`, c.schemas().escape(name))
 	return c.Execute(query)
 }
 
@@ -285,10 +346,59 @@ func (c *Client) TruncateTable(name string) error {
 		return errors.New("table name is empty")
 	}
 
-	query := fmt.Sprintf(`DELETE FROM %q`, name)
+	query := fmt.Sprintf(`DELETE FROM %s`, c.schemas().escape(name))
 	return c.Execute(query)
 }
 
+func (c *Client) DescribeTable(name string) ([]Column, error) {
+	if name == "" {
+		return nil, errors.New("table name is empty")
+	}
+
+	schema, table := c.schemas().split(name)
+	pragma := "PRAGMA "
+	if schema != "" {
+		pragma += escapeIdentifier(schema) + "."
+	}
+	pragma += fmt.Sprintf("table_info(%s)", escapeIdentifier(table))
+
+	rows, err := c.ExecSelect(pragma)
+	if err != nil {
+		return nil, fmt.Errorf("describe table %q: %w", name, err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("table %q not found", name)
+	}
+
+	columns := make([]Column, 0, len(rows))
+	for _, row := range rows {
+		column := Column{
+			Name: fmt.Sprint(row["name"]),
+			Type: ColumnType(strings.ToUpper(fmt.Sprint(row["type"]))),
+		}
+		if pk, ok := row["pk"].(int64); ok && pk > 0 {
+			column.PrimaryKey = boolPtr(true)
+		}
+		if notNull, ok := row["notnull"].(int64); ok && notNull > 0 {
+			column.NotNull = boolPtr(true)
+		}
+		if def, ok := row["dflt_value"].(string); ok {
+			column.Default = stringPtr(def)
+		}
+		columns = append(columns, column)
+	}
+
+	return columns, nil
+}
+
+func scanRows(rows *external.Rows) ([]map[string]any, error) {
+	result, err := scanResult(rows)
+	if err != nil {
+		return nil, err
+	}
+	return result.Maps(), nil
+}
+
 func getDbPath() string {
 	if path := os.Getenv("DB_PATH"); path != "" {
 		return path
@@ -296,3 +406,7 @@ func getDbPath() string {
 
 	return dbDefaultPath
 }
+
+func getDbFile(name string) string {
+	return filepath.Join(getDbPath(), name+".sqlite")
+}
@@ -0,0 +1,193 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	PlanScan   = "SCAN"
+	PlanSearch = "SEARCH"
+)
+
+var (
+	planIndexRegexp  = regexp.MustCompile(`\bUSING (COVERING )?INDEX (\S+)`)
+	tableAliasRegexp = regexp.MustCompile(
+		`(?i)\b(?:FROM|JOIN)\s+((?:"[^"]+"|\w+)(?:\.(?:"[^"]+"|\w+))?)\s+(?:AS\s+)?("[^"]+"|\w+)`,
+	)
+)
+
+var aliasKeywords = map[string]bool{
+	"WHERE": true, "JOIN": true, "INNER": true, "LEFT": true, "RIGHT": true, "FULL": true, "CROSS": true,
+	"NATURAL": true, "ON": true, "USING": true, "GROUP": true, "ORDER": true, "LIMIT": true, "HAVING": true,
+	"WINDOW": true, "UNION": true, "EXCEPT": true, "INTERSECT": true, "INDEXED": true, "NOT": true,
+}
+
+type PlanNode struct {
+	ID       int64
+	Parent   int64
+	Detail   string
+	Op       string
+	Table    string
+	Index    string
+	Covering bool
+	Children []*PlanNode
+}
+
+func (c *Client) Explain(query string, args ...any) ([]*PlanNode, error) {
+	if c == nil || c.db == nil {
+		return nil, errors.New("db client is nil")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if c.mutex != nil {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+	}
+
+	return c.explain(ctx, query, args...)
+}
+
+func (c *Client) SetDevMode(scanThreshold int64) {
+	if c == nil {
+		return
+	}
+	c.scanThreshold.Store(scanThreshold)
+}
+
+func (c *Client) explain(ctx context.Context, query string, args ...any) ([]*PlanNode, error) {
+	rows, err := c.db.QueryContext(ctx, "EXPLAIN QUERY PLAN "+query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("explain query: %w", err)
+	}
+	defer rows.Close()
+
+	var roots []*PlanNode
+	byID := map[int64]*PlanNode{}
+	for rows.Next() {
+		var notUsed int64
+		node := &PlanNode{}
+		if err := rows.Scan(&node.ID, &node.Parent, &notUsed, &node.Detail); err != nil {
+			return nil, fmt.Errorf("scan plan: %w", err)
+		}
+		parsePlanDetail(node)
+
+		byID[node.ID] = node
+		if parent, ok := byID[node.Parent]; ok {
+			parent.Children = append(parent.Children, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows err: %w", err)
+	}
+
+	return roots, nil
+}
+
+func parsePlanDetail(node *PlanNode) {
+	node.Op = node.Detail
+
+	fields := strings.Fields(node.Detail)
+	if len(fields) < 2 || (fields[0] != PlanScan && fields[0] != PlanSearch) {
+		return
+	}
+	node.Op = fields[0]
+	if fields[1] == "CONSTANT" {
+		return
+	}
+	node.Table = fields[1]
+
+	if match := planIndexRegexp.FindStringSubmatch(node.Detail); match != nil {
+		node.Covering = match[1] != ""
+		node.Index = match[2]
+	}
+}
+
+func (c *Client) warnFullScans(ctx context.Context, threshold int64, query string, args ...any) {
+	plan, err := c.explain(ctx, query, args...)
+	if err != nil {
+		log.Debugf("Can not explain query: %v", err)
+		return
+	}
+
+	aliases := queryTableAliases(query)
+	walkPlan(plan, func(node *PlanNode) {
+		if node.Op != PlanScan || node.Table == "" {
+			return
+		}
+
+		table := node.Table
+		if target, ok := aliases[strings.ToLower(table)]; ok {
+			table = target
+		}
+
+		rows, ok := c.tableRowCount(ctx, table)
+		if !ok || rows < threshold {
+			return
+		}
+
+		how := "full table scan"
+		if node.Index != "" {
+			how = fmt.Sprintf("full scan of index %q", node.Index)
+		}
+		log.Warnf(
+			"SQLite query does a %s of table %q with %d rows, consider an index on the filtered columns: %s",
+			how, table, rows, strings.Join(strings.Fields(query), " "),
+		)
+	})
+}
+
+func walkPlan(nodes []*PlanNode, fn func(node *PlanNode)) {
+	for _, node := range nodes {
+		fn(node)
+		walkPlan(node.Children, fn)
+	}
+}
+
+func queryTableAliases(query string) map[string]string {
+	aliases := map[string]string{}
+	for _, match := range tableAliasRegexp.FindAllStringSubmatch(query, -1) {
+		alias := strings.Trim(match[2], `"`)
+		if aliasKeywords[strings.ToUpper(alias)] {
+			continue
+		}
+		aliases[strings.ToLower(alias)] = strings.ReplaceAll(match[1], `"`, "")
+	}
+	return aliases
+}
+
+// Prefers ANALYZE statistics, a view, CTE or subquery has no row count
+func (c *Client) tableRowCount(ctx context.Context, table string) (int64, bool) {
+	schema, name := c.schemas().split(table)
+	prefix := ""
+	if schema != "" {
+		prefix = escapeIdentifier(schema) + "."
+	}
+
+	tables, err := c.queryStrings(ctx, fmt.Sprintf("SELECT name FROM %ssqlite_master WHERE type = 'table' AND name = ? COLLATE NOCASE", prefix), name)
+	if err != nil || len(tables) == 0 {
+		return 0, false
+	}
+
+	stats, err := c.queryStrings(ctx, fmt.Sprintf("SELECT stat FROM %ssqlite_stat1 WHERE tbl = ? LIMIT 1", prefix), tables[0])
+	if err == nil && len(stats) > 0 {
+		if rows, _ := parseIndexStat(stats[0]); rows > 0 {
+			return rows, true
+		}
+	}
+
+	var rows int64
+	if err := c.db.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s%s", prefix, escapeIdentifier(tables[0]))).Scan(&rows); err != nil {
+		return 0, false
+	}
+	return rows, true
+}
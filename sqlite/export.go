@@ -0,0 +1,453 @@
+package sqlite
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type DataFormat string
+
+const (
+	FormatCSV   DataFormat = "csv"
+	FormatJSONL DataFormat = "jsonl"
+	FormatSQL   DataFormat = "sql"
+
+	// Only for Result.Render
+	FormatText     DataFormat = "text"
+	FormatMarkdown DataFormat = "markdown"
+	FormatHTML     DataFormat = "html"
+)
+
+type rowEncoder interface {
+	header(columns []string) error
+	row(values []any) error
+	flush() error
+}
+
+func (c *Client) ExportTable(ctx context.Context, table string, w io.Writer, format DataFormat) error {
+	if c == nil || c.db == nil {
+		return errors.New("db client is nil")
+	}
+	if table == "" {
+		return errors.New("table name is empty")
+	}
+
+	if c.mutex != nil {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+	}
+
+	buffered := bufio.NewWriter(w)
+	if format == FormatSQL {
+		if err := c.writeTableSchema(ctx, buffered, table); err != nil {
+			return err
+		}
+	}
+
+	query := fmt.Sprintf("SELECT * FROM %s", c.schemas().escape(table))
+	if err := c.export(ctx, buffered, format, table, query); err != nil {
+		return fmt.Errorf("export table %q: %w", table, err)
+	}
+
+	if format == FormatSQL {
+		if err := c.writeTableIndexes(ctx, buffered, table); err != nil {
+			return err
+		}
+	}
+
+	return buffered.Flush()
+}
+
+func (c *Client) ExportQuery(ctx context.Context, w io.Writer, format DataFormat, query string, args ...any) error {
+	if c == nil || c.db == nil {
+		return errors.New("db client is nil")
+	}
+	if format == FormatSQL {
+		return errors.New("sql format needs a target table, use ExportTable")
+	}
+
+	if c.mutex != nil {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+	}
+
+	buffered := bufio.NewWriter(w)
+	if err := c.export(ctx, buffered, format, "", query, args...); err != nil {
+		return fmt.Errorf("export query: %w", err)
+	}
+	return buffered.Flush()
+}
+
+func (c *Client) Dump(w io.Writer) error {
+	if c == nil || c.db == nil {
+		return errors.New("db client is nil")
+	}
+	ctx := context.Background()
+
+	if c.mutex != nil {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+	}
+
+	tables, err := c.dumpTables(ctx, "table")
+	if err != nil {
+		return fmt.Errorf("dump tables: %w", err)
+	}
+	// Shadow tables are left out, the module fills them again from the rows of its virtual table
+	virtualTables, err := c.dumpTables(ctx, "virtual")
+	if err != nil {
+		return fmt.Errorf("dump virtual tables: %w", err)
+	}
+
+	buffered := bufio.NewWriter(w)
+	if _, err := buffered.WriteString("PRAGMA foreign_keys=OFF;\nBEGIN TRANSACTION;\n"); err != nil {
+		return err
+	}
+
+	for _, table := range tables {
+		if err := c.writeTableSchema(ctx, buffered, table); err != nil {
+			return err
+		}
+		query := fmt.Sprintf("SELECT * FROM %s", escapeIdentifier(table))
+		if err := c.export(ctx, buffered, FormatSQL, table, query); err != nil {
+			return fmt.Errorf("dump table %q: %w", table, err)
+		}
+	}
+
+	for _, table := range virtualTables {
+		if err := c.writeTableSchema(ctx, buffered, table); err != nil {
+			return err
+		}
+		query := fmt.Sprintf("SELECT * FROM %s", escapeIdentifier(table))
+		if err := c.export(ctx, buffered, FormatSQL, table, query); err != nil {
+			return fmt.Errorf("dump virtual table %q: %w", table, err)
+		}
+	}
+
+	sequences, err := c.queryStrings(ctx, `SELECT name FROM sqlite_master WHERE type = 'table' AND name = 'sqlite_sequence'`)
+	if err != nil {
+		return fmt.Errorf("dump sequences: %w", err)
+	}
+	if len(sequences) > 0 {
+		if _, err := buffered.WriteString("DELETE FROM sqlite_sequence;\n"); err != nil {
+			return err
+		}
+		if err := c.export(ctx, buffered, FormatSQL, "sqlite_sequence", "SELECT * FROM sqlite_sequence"); err != nil {
+			return fmt.Errorf("dump sequences: %w", err)
+		}
+	}
+
+	objects, err := c.queryStrings(ctx, `SELECT sql FROM sqlite_master
+		WHERE type IN ('index', 'trigger', 'view') AND sql IS NOT NULL
+		ORDER BY CASE type WHEN 'index' THEN 0 WHEN 'view' THEN 1 ELSE 2 END, rowid`)
+	if err != nil {
+		return fmt.Errorf("dump schema objects: %w", err)
+	}
+	for _, object := range objects {
+		if _, err := buffered.WriteString(object + ";\n"); err != nil {
+			return err
+		}
+	}
+
+	if _, err := buffered.WriteString("COMMIT;\n"); err != nil {
+		return err
+	}
+	return buffered.Flush()
+}
+
+func (c *Client) export(ctx context.Context, w io.Writer, format DataFormat, table string, query string, args ...any) error {
+	if c == nil || c.db == nil {
+		return errors.New("db client is nil")
+	}
+
+	var encoder rowEncoder
+	switch format {
+	case FormatCSV:
+		encoder = &csvEncoder{writer: csv.NewWriter(w)}
+	case FormatJSONL:
+		encoder = &jsonlEncoder{writer: w}
+	case FormatSQL:
+		encoder = &sqlEncoder{writer: w, table: c.schemas().escape(table)}
+	default:
+		return fmt.Errorf("unsupported format: %s", format)
+	}
+
+	return c.intercept(ctx, &Query{Kind: QuerySelect, SQL: query, Args: args}, func(ctx context.Context, q *Query) error {
+		rows, err := c.db.QueryContext(ctx, q.SQL, q.Args...)
+		if err != nil {
+			return fmt.Errorf("select query: %w", err)
+		}
+		defer rows.Close()
+
+		columns, err := rows.Columns()
+		if err != nil {
+			return fmt.Errorf("columns: %w", err)
+		}
+		if err := encoder.header(columns); err != nil {
+			return err
+		}
+
+		raw := make([]any, len(columns))
+		ptrs := make([]any, len(columns))
+		for i := range raw {
+			ptrs[i] = &raw[i]
+		}
+
+		for rows.Next() {
+			if err := rows.Scan(ptrs...); err != nil {
+				return fmt.Errorf("scan: %w", err)
+			}
+			if err := encoder.row(raw); err != nil {
+				return err
+			}
+			q.Rows++
+		}
+
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("rows err: %w", err)
+		}
+
+		return encoder.flush()
+	})
+}
+
+// Kind is the type of pragma table_list: table, virtual or shadow
+func (c *Client) dumpTables(ctx context.Context, kind string) ([]string, error) {
+	return c.queryStrings(ctx, `SELECT master.name FROM sqlite_master AS master
+		JOIN pragma_table_list AS list ON list.schema = 'main' AND list.name = master.name
+		WHERE master.type = 'table' AND list.type = ? AND master.name NOT LIKE 'sqlite_%'
+		ORDER BY master.rowid`, kind)
+}
+
+func (c *Client) writeTableSchema(ctx context.Context, w io.Writer, table string) error {
+	schema, name := c.schemas().split(table)
+	master := "sqlite_master"
+	if schema != "" {
+		master = escapeIdentifier(schema) + ".sqlite_master"
+	}
+
+	statements, err := c.queryStrings(ctx, fmt.Sprintf(`SELECT sql FROM %s WHERE type = 'table' AND name = ?`, master), name)
+	if err != nil {
+		return fmt.Errorf("schema of %q: %w", table, err)
+	}
+	if len(statements) == 0 {
+		return fmt.Errorf("table %q not found", table)
+	}
+
+	_, err = io.WriteString(w, statements[0]+";\n")
+	return err
+}
+
+func (c *Client) writeTableIndexes(ctx context.Context, w io.Writer, table string) error {
+	schema, name := c.schemas().split(table)
+	master := "sqlite_master"
+	if schema != "" {
+		master = escapeIdentifier(schema) + ".sqlite_master"
+	}
+
+	statements, err := c.queryStrings(ctx, fmt.Sprintf(
+		`SELECT sql FROM %s WHERE type IN ('index', 'trigger') AND tbl_name = ? AND sql IS NOT NULL ORDER BY rowid`, master,
+	), name)
+	if err != nil {
+		return fmt.Errorf("indexes of %q: %w", table, err)
+	}
+
+	for _, statement := range statements {
+		if _, err := io.WriteString(w, statement+";\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Client) queryStrings(ctx context.Context, query string, args ...any) ([]string, error) {
+	rows, err := c.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		out = append(out, value)
+	}
+	return out, rows.Err()
+}
+
+type csvEncoder struct {
+	writer *csv.Writer
+	record []string
+}
+
+func (e *csvEncoder) header(columns []string) error {
+	e.record = make([]string, len(columns))
+	return e.writer.Write(columns)
+}
+
+func (e *csvEncoder) row(values []any) error {
+	for i, value := range values {
+		e.record[i] = textValue(value)
+	}
+	return e.writer.Write(e.record)
+}
+
+func (e *csvEncoder) flush() error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+type jsonlEncoder struct {
+	writer io.Writer
+	keys   [][]byte
+}
+
+func (e *jsonlEncoder) header(columns []string) error {
+	e.keys = make([][]byte, len(columns))
+	for i, column := range columns {
+		key, err := json.Marshal(column)
+		if err != nil {
+			return err
+		}
+		e.keys[i] = key
+	}
+	return nil
+}
+
+func (e *jsonlEncoder) row(values []any) error {
+	var line strings.Builder
+	line.WriteByte('{')
+	for i, value := range values {
+		if i > 0 {
+			line.WriteByte(',')
+		}
+		line.Write(e.keys[i])
+		line.WriteByte(':')
+
+		if b, ok := value.([]byte); ok {
+			value = string(b)
+		}
+		if t, ok := value.(time.Time); ok {
+			value = formatTime(t)
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		line.Write(encoded)
+	}
+	line.WriteString("}\n")
+
+	_, err := io.WriteString(e.writer, line.String())
+	return err
+}
+
+func (e *jsonlEncoder) flush() error {
+	return nil
+}
+
+type sqlEncoder struct {
+	writer io.Writer
+	// Already escaped
+	table   string
+	columns string
+}
+
+func (e *sqlEncoder) header(columns []string) error {
+	e.columns = joinEscapedIdentifiers(columns)
+	return nil
+}
+
+func (e *sqlEncoder) row(values []any) error {
+	literals := make([]string, len(values))
+	for i, value := range values {
+		literals[i] = sqlLiteral(value)
+	}
+
+	_, err := fmt.Fprintf(
+		e.writer,
+		"INSERT INTO %s (%s) VALUES (%s);\n",
+		e.table,
+		e.columns,
+		strings.Join(literals, ", "),
+	)
+	return err
+}
+
+func (e *sqlEncoder) flush() error {
+	return nil
+}
+
+func textValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	case time.Time:
+		return formatTime(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func sqlLiteral(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		if math.IsInf(v, 1) {
+			return "1e999"
+		}
+		if math.IsInf(v, -1) {
+			return "-1e999"
+		}
+		if math.IsNaN(v) {
+			return "NULL"
+		}
+		literal := strconv.FormatFloat(v, 'g', -1, 64)
+		if !strings.ContainsAny(literal, ".eE") {
+			literal += ".0"
+		}
+		return literal
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case []byte:
+		return "X'" + strings.ToUpper(hex.EncodeToString(v)) + "'"
+	case time.Time:
+		return quoteLiteral(formatTime(v))
+	case string:
+		return quoteLiteral(v)
+	default:
+		return quoteLiteral(fmt.Sprint(v))
+	}
+}
+
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func formatTime(t time.Time) string {
+	if t.Location() == time.UTC && t.Nanosecond() == 0 {
+		return t.Format(time.DateTime)
+	}
+	return t.Format("2006-01-02 15:04:05.999999999-07:00")
+}
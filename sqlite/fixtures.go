@@ -0,0 +1,547 @@
+package sqlite
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	fixtureLabelKey = "_label"
+	fixtureTimeout  = 5 * time.Minute
+)
+
+var fixtureTemplateRegexp = regexp.MustCompile(`\{\{\s*([a-zA-Z_]+)\(([^)]*)\)\s*\}\}`)
+
+type FixtureMode string
+
+const (
+	FixtureInsert  FixtureMode = "INSERT"
+	FixtureUpsert  FixtureMode = "UPSERT"
+	FixtureReplace FixtureMode = "REPLACE"
+)
+
+type fixtureRow struct {
+	label  string
+	values map[string]any
+}
+
+type fixtureTable struct {
+	name        string
+	rows        []fixtureRow
+	primaryKeys []string
+	dependsOn   map[string]bool
+}
+
+type loadedFixture struct {
+	key    any
+	values map[string]any
+}
+
+type fixtureLoader struct {
+	now    time.Time
+	tables map[string]*fixtureTable
+	loaded map[string]loadedFixture
+}
+
+func (c *Client) LoadFixtures(fsys fs.FS, files ...string) error {
+	return c.LoadFixturesWithMode(fsys, FixtureInsert, files...)
+}
+
+func (c *Client) LoadFixturesWithMode(fsys fs.FS, mode FixtureMode, files ...string) error {
+	if c == nil || c.db == nil {
+		return errors.New("db client is nil")
+	}
+	if fsys == nil {
+		return errors.New("fixtures fs is nil")
+	}
+	switch mode {
+	case FixtureInsert, FixtureUpsert, FixtureReplace:
+	default:
+		return fmt.Errorf("invalid fixture mode: %s", mode)
+	}
+
+	loader := &fixtureLoader{
+		now:    time.Now().UTC(),
+		tables: map[string]*fixtureTable{},
+		loaded: map[string]loadedFixture{},
+	}
+
+	var order []string
+	for _, file := range files {
+		parsed, err := parseFixtureFile(fsys, file)
+		if err != nil {
+			return fmt.Errorf("fixture %q: %w", file, err)
+		}
+		for _, table := range parsed {
+			existing, ok := loader.tables[table.name]
+			if !ok {
+				loader.tables[table.name] = table
+				order = append(order, table.name)
+				continue
+			}
+			existing.rows = append(existing.rows, table.rows...)
+		}
+	}
+
+	for _, name := range order {
+		if err := c.describeFixtureTable(loader.tables[name]); err != nil {
+			return err
+		}
+	}
+
+	sorted := sortFixtureTables(order, loader.tables)
+
+	return c.transactionWithTimeout(fixtureTimeout, func(tx *Tx) error {
+		if err := tx.Execute("PRAGMA defer_foreign_keys = ON"); err != nil {
+			return err
+		}
+		for _, name := range sorted {
+			if err := loader.insertTable(tx, loader.tables[name], mode); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (c *Client) describeFixtureTable(t *fixtureTable) error {
+	schema, table := c.schemas().split(t.name)
+	prefix := ""
+	if schema != "" {
+		prefix = escapeIdentifier(schema) + "."
+	}
+
+	columns, err := c.ExecSelect(fmt.Sprintf("PRAGMA %stable_info(%s)", prefix, escapeIdentifier(table)))
+	if err != nil {
+		return fmt.Errorf("describe fixture table %q: %w", t.name, err)
+	}
+	if len(columns) == 0 {
+		return fmt.Errorf("fixture table %q not found", t.name)
+	}
+	for _, column := range columns {
+		if pk, ok := column["pk"].(int64); ok && pk > 0 {
+			t.primaryKeys = append(t.primaryKeys, fmt.Sprint(column["name"]))
+		}
+	}
+
+	foreignKeys, err := c.ExecSelect(fmt.Sprintf("PRAGMA %sforeign_key_list(%s)", prefix, escapeIdentifier(table)))
+	if err != nil {
+		return fmt.Errorf("foreign keys of fixture table %q: %w", t.name, err)
+	}
+	for _, fk := range foreignKeys {
+		reference := fmt.Sprint(fk["table"])
+		if schema != "" {
+			reference = schema + "." + reference
+		}
+		t.dependsOn[reference] = true
+	}
+
+	for _, row := range t.rows {
+		for _, value := range row.values {
+			s, ok := value.(string)
+			if !ok {
+				continue
+			}
+			for _, m := range fixtureTemplateRegexp.FindAllStringSubmatch(s, -1) {
+				if m[1] != "ref" {
+					continue
+				}
+				if reference, _, _, err := parseFixtureRef(m[2]); err == nil {
+					t.dependsOn[reference] = true
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func sortFixtureTables(order []string, tables map[string]*fixtureTable) []string {
+	sorted := make([]string, 0, len(order))
+	done := map[string]bool{}
+	visiting := map[string]bool{}
+
+	var visit func(name string)
+	visit = func(name string) {
+		if done[name] || visiting[name] {
+			// Cycles are left to deferred foreign keys; row references across them cannot resolve
+			return
+		}
+		visiting[name] = true
+
+		deps := make([]string, 0, len(tables[name].dependsOn))
+		for dep := range tables[name].dependsOn {
+			if _, ok := tables[dep]; ok && dep != name {
+				deps = append(deps, dep)
+			}
+		}
+		sort.Slice(deps, func(i, j int) bool {
+			return indexOf(order, deps[i]) < indexOf(order, deps[j])
+		})
+		for _, dep := range deps {
+			visit(dep)
+		}
+
+		visiting[name] = false
+		done[name] = true
+		sorted = append(sorted, name)
+	}
+
+	for _, name := range order {
+		visit(name)
+	}
+
+	return sorted
+}
+
+func (l *fixtureLoader) insertTable(tx *Tx, t *fixtureTable, mode FixtureMode) error {
+	names := tx.client.schemas()
+	for i, row := range t.rows {
+		values := make(map[string]any, len(row.values))
+		for column, value := range row.values {
+			resolved, err := l.resolve(value)
+			if err != nil {
+				return fmt.Errorf("fixture %q row %d column %q: %w", t.name, i, column, err)
+			}
+			values[column] = resolved
+		}
+		if len(values) == 0 {
+			continue
+		}
+
+		query, args := buildFixtureInsertSQL(t.name, names, values, mode)
+		result, err := tx.exec(query, args...)
+		if err != nil {
+			return fmt.Errorf("fixture %q row %d: %w", t.name, i, err)
+		}
+
+		if row.label != "" {
+			loaded := loadedFixture{values: values}
+			if id, err := result.LastInsertId(); err == nil {
+				loaded.key = id
+			}
+			if len(t.primaryKeys) == 1 {
+				if key, ok := values[t.primaryKeys[0]]; ok {
+					loaded.key = key
+				}
+			}
+			l.loaded[t.name+"."+row.label] = loaded
+		}
+	}
+
+	return nil
+}
+
+func buildFixtureInsertSQL(table string, names schemaNames, values map[string]any, mode FixtureMode) (string, []any) {
+	columns := make([]string, 0, len(values))
+	for column := range values {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	holders := make([]string, 0, len(columns))
+	args := make([]any, 0, len(columns))
+	for _, column := range columns {
+		holders = append(holders, "?")
+		args = append(args, values[column])
+	}
+
+	insert := "INSERT INTO"
+	if mode == FixtureReplace {
+		insert = "INSERT OR REPLACE INTO"
+	}
+
+	query := fmt.Sprintf(
+		"%s %s (%s) VALUES (%s)",
+		insert,
+		names.escape(table),
+		joinEscapedIdentifiers(columns),
+		strings.Join(holders, ", "),
+	)
+
+	if mode == FixtureUpsert {
+		updates := make([]string, 0, len(columns))
+		for _, column := range columns {
+			updates = append(updates, fmt.Sprintf("%s = excluded.%s", escapeIdentifier(column), escapeIdentifier(column)))
+		}
+		query += " ON CONFLICT DO UPDATE SET " + strings.Join(updates, ", ")
+	}
+
+	return query, args
+}
+
+func (l *fixtureLoader) resolve(value any) (any, error) {
+	s, ok := value.(string)
+	if !ok {
+		return value, nil
+	}
+
+	matches := fixtureTemplateRegexp.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 0 {
+		return value, nil
+	}
+
+	if len(matches) == 1 && matches[0][0] == 0 && matches[0][1] == len(s) {
+		return l.evaluate(s[matches[0][2]:matches[0][3]], s[matches[0][4]:matches[0][5]])
+	}
+
+	var out strings.Builder
+	last := 0
+	for _, m := range matches {
+		out.WriteString(s[last:m[0]])
+		v, err := l.evaluate(s[m[2]:m[3]], s[m[4]:m[5]])
+		if err != nil {
+			return nil, err
+		}
+		out.WriteString(fmt.Sprint(v))
+		last = m[1]
+	}
+	out.WriteString(s[last:])
+
+	return out.String(), nil
+}
+
+func (l *fixtureLoader) evaluate(function string, argument string) (any, error) {
+	argument = strings.TrimSpace(argument)
+
+	switch function {
+	case "now":
+		now := l.now
+		if argument != "" {
+			offset, err := time.ParseDuration(argument)
+			if err != nil {
+				return nil, fmt.Errorf("now(%s): %w", argument, err)
+			}
+			now = now.Add(offset)
+		}
+		return now.Format(time.DateTime), nil
+	case "ref":
+		table, label, column, err := parseFixtureRef(argument)
+		if err != nil {
+			return nil, err
+		}
+		row, ok := l.loaded[table+"."+label]
+		if !ok {
+			return nil, fmt.Errorf("unknown fixture reference %q", table+"."+label)
+		}
+		if column == "" {
+			return row.key, nil
+		}
+		value, ok := row.values[column]
+		if !ok {
+			return nil, fmt.Errorf("fixture reference %q has no column %q", table+"."+label, column)
+		}
+		return value, nil
+	default:
+		return nil, fmt.Errorf("unknown fixture function %q", function)
+	}
+}
+
+func parseFixtureRef(argument string) (string, string, string, error) {
+	target, column, _ := strings.Cut(argument, ",")
+	target = strings.TrimSpace(target)
+	column = strings.TrimSpace(column)
+
+	i := strings.LastIndex(target, ".")
+	if i <= 0 || i == len(target)-1 {
+		return "", "", "", fmt.Errorf("invalid fixture reference %q, expected ref(table.label[, column])", argument)
+	}
+
+	return target[:i], target[i+1:], column, nil
+}
+
+func parseFixtureFile(fsys fs.FS, file string) ([]*fixtureTable, error) {
+	data, err := fs.ReadFile(fsys, file)
+	if err != nil {
+		return nil, err
+	}
+
+	ext := strings.ToLower(path.Ext(file))
+	defaultTable := strings.TrimSuffix(path.Base(file), path.Ext(file))
+
+	switch ext {
+	case ".csv":
+		rows, err := parseFixtureCSV(data)
+		if err != nil {
+			return nil, err
+		}
+		return []*fixtureTable{newFixtureTable(defaultTable, rows)}, nil
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		var document any
+		if err := decoder.Decode(&document); err != nil {
+			return nil, err
+		}
+		return parseFixtureDocument(defaultTable, document)
+	case ".yaml", ".yml":
+		var document any
+		if err := yaml.Unmarshal(data, &document); err != nil {
+			return nil, err
+		}
+		return parseFixtureDocument(defaultTable, document)
+	default:
+		return nil, fmt.Errorf("unsupported fixture format %q", ext)
+	}
+}
+
+func parseFixtureDocument(defaultTable string, document any) ([]*fixtureTable, error) {
+	switch doc := document.(type) {
+	case []any:
+		rows, err := parseFixtureRows(doc)
+		if err != nil {
+			return nil, err
+		}
+		return []*fixtureTable{newFixtureTable(defaultTable, rows)}, nil
+	case map[string]any:
+		names := make([]string, 0, len(doc))
+		for name := range doc {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		tables := make([]*fixtureTable, 0, len(names))
+		for _, name := range names {
+			rows, err := parseFixtureRows(doc[name])
+			if err != nil {
+				return nil, fmt.Errorf("table %q: %w", name, err)
+			}
+			tables = append(tables, newFixtureTable(name, rows))
+		}
+		return tables, nil
+	case nil:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("fixture document must be a list of rows or a map of tables, got %T", document)
+	}
+}
+
+func parseFixtureRows(raw any) ([]fixtureRow, error) {
+	switch rows := raw.(type) {
+	case []any:
+		out := make([]fixtureRow, 0, len(rows))
+		for i, item := range rows {
+			values, ok := item.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("row %d must be an object, got %T", i, item)
+			}
+			out = append(out, newFixtureRow("", values))
+		}
+		return out, nil
+	case map[string]any:
+		labels := make([]string, 0, len(rows))
+		for label := range rows {
+			labels = append(labels, label)
+		}
+		sort.Strings(labels)
+
+		out := make([]fixtureRow, 0, len(rows))
+		for _, label := range labels {
+			values, ok := rows[label].(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("row %q must be an object, got %T", label, rows[label])
+			}
+			out = append(out, newFixtureRow(label, values))
+		}
+		return out, nil
+	case nil:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("rows must be a list or a map of labelled rows, got %T", raw)
+	}
+}
+
+func parseFixtureCSV(data []byte) ([]fixtureRow, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var rows []fixtureRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		values := make(map[string]any, len(header))
+		for i, column := range header {
+			if record[i] == "" {
+				values[column] = nil
+			} else {
+				values[column] = record[i]
+			}
+		}
+		rows = append(rows, newFixtureRow("", values))
+	}
+
+	return rows, nil
+}
+
+func newFixtureTable(name string, rows []fixtureRow) *fixtureTable {
+	return &fixtureTable{name: name, rows: rows, dependsOn: map[string]bool{}}
+}
+
+func newFixtureRow(label string, raw map[string]any) fixtureRow {
+	values := make(map[string]any, len(raw))
+	for column, value := range raw {
+		if column == fixtureLabelKey {
+			if value != nil {
+				label = fmt.Sprint(value)
+			}
+			continue
+		}
+		values[column] = fixtureValue(value)
+	}
+	return fixtureRow{label: label, values: values}
+}
+
+func fixtureValue(value any) any {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v.String()
+	case map[string]any, []any:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(encoded)
+	default:
+		return value
+	}
+}
+
+func indexOf(items []string, item string) int {
+	for i, v := range items {
+		if v == item {
+			return i
+		}
+	}
+	return len(items)
+}
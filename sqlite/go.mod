@@ -2,7 +2,11 @@ module github.com/halushko/core-go/sqlite
 
 go 1.27
 
-require modernc.org/sqlite v1.57.0
+require (
+	github.com/sirupsen/logrus v1.10.1
+	gopkg.in/yaml.v3 v3.0.1
+	modernc.org/sqlite v1.57.0
+)
 
 require (
 	github.com/dustin/go-humanize v1.0.1 // indirect
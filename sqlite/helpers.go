@@ -1,10 +1,17 @@
 package sqlite
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
 )
 
+// Sorts as text next to strftime('%Y-%m-%d %H:%M:%f')
+const (
+	millisTimeLayout = "2006-01-02 15:04:05.000"
+	nowMillisSQL     = "strftime('%Y-%m-%d %H:%M:%f', 'now')"
+)
+
 func boolPtr(v bool) *bool {
 	return &v
 }
@@ -33,3 +40,46 @@ func (t ColumnType) validateColumnType() error {
 		return fmt.Errorf("invalid column type: %s", t)
 	}
 }
+
+// Schemas a name can be qualified with: main, temp and the aliases attached to one client
+type schemaNames map[string]bool
+
+func (s schemaNames) has(name string) bool {
+	switch key := strings.ToLower(name); key {
+	case "main", "temp":
+		return true
+	default:
+		return s[key]
+	}
+}
+
+// The prefix is only a schema when it is main, temp or an attached alias, so "my.table" stays one name
+func (s schemaNames) split(name string) (string, string) {
+	if i := strings.Index(name, "."); i > 0 && i < len(name)-1 && s.has(name[:i]) {
+		return name[:i], name[i+1:]
+	}
+	return "", name
+}
+
+func (s schemaNames) escape(name string) string {
+	schema, object := s.split(name)
+	if schema == "" {
+		return escapeIdentifier(object)
+	}
+	return escapeIdentifier(schema) + "." + escapeIdentifier(object)
+}
+
+func encodeValue(value any) ([]byte, error) {
+	if raw, ok := value.([]byte); ok {
+		return raw, nil
+	}
+	return json.Marshal(value)
+}
+
+func decodeValue(raw []byte, dest any) error {
+	if target, ok := dest.(*[]byte); ok {
+		*target = append((*target)[:0], raw...)
+		return nil
+	}
+	return json.Unmarshal(raw, dest)
+}
@@ -0,0 +1,385 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+func (c *Client) AsOf(table string, at time.Time) ([]map[string]any, error) {
+	if c == nil || c.db == nil {
+		return nil, errors.New("db client is nil")
+	}
+
+	history, keys, err := c.historyOf(table)
+	if err != nil {
+		return nil, err
+	}
+
+	validFrom, validTo := escapeIdentifier(ColumnValidFrom), escapeIdentifier(ColumnValidTo)
+	query := fmt.Sprintf(
+		"SELECT * FROM %s WHERE %s <= ? AND (%s IS NULL OR %s > ?) ORDER BY %s",
+		c.schemas().escape(history), validFrom, validTo, validTo, joinEscapedIdentifiers(keys),
+	)
+	moment := at.UTC().Format(millisTimeLayout)
+
+	rows, err := c.ExecSelect(query, moment, moment)
+	if err != nil {
+		return nil, fmt.Errorf("%q as of %s: %w", table, moment, err)
+	}
+	return rows, nil
+}
+
+// Versions of one row from the oldest, pk holds the primary key values in key column order
+func (c *Client) History(table string, pk ...any) ([]map[string]any, error) {
+	if c == nil || c.db == nil {
+		return nil, errors.New("db client is nil")
+	}
+
+	history, keys, err := c.historyOf(table)
+	if err != nil {
+		return nil, err
+	}
+	if len(pk) != len(keys) {
+		return nil, fmt.Errorf("history of %q: got %d key values for primary key %v", table, len(pk), keys)
+	}
+
+	conditions := make([]string, len(keys))
+	for i, key := range keys {
+		conditions[i] = escapeIdentifier(key) + " IS ?"
+	}
+	query := fmt.Sprintf(
+		"SELECT * FROM %s WHERE %s ORDER BY %s, %s",
+		c.schemas().escape(history), strings.Join(conditions, " AND "),
+		escapeIdentifier(ColumnValidFrom), escapeIdentifier(ColumnHistoryID),
+	)
+
+	rows, err := c.ExecSelect(query, pk...)
+	if err != nil {
+		return nil, fmt.Errorf("history of %q: %w", table, err)
+	}
+	return rows, nil
+}
+
+func (c *Client) historyOf(table string) (string, []string, error) {
+	if table == "" {
+		return "", nil, errors.New("table name is empty")
+	}
+
+	schema, name := c.schemas().split(table)
+	if schema == "" {
+		schema = "main"
+	}
+
+	enabled, err := c.hasHistory(table)
+	if err != nil {
+		return "", nil, fmt.Errorf("history of %q: %w", table, err)
+	}
+	if !enabled {
+		return "", nil, fmt.Errorf("table %q has no history", table)
+	}
+
+	keys, err := c.ExecSelect("SELECT name FROM pragma_table_info(?, ?) WHERE pk > 0 ORDER BY pk", name, schema)
+	if err != nil {
+		return "", nil, fmt.Errorf("history of %q: %w", table, err)
+	}
+	if len(keys) == 0 {
+		return "", nil, fmt.Errorf("table %q has no primary key", table)
+	}
+
+	columns := make([]string, len(keys))
+	for i, key := range keys {
+		columns[i] = fmt.Sprint(key["name"])
+	}
+	return table + HistoryTableSuffix, columns, nil
+}
+
+func (c *Client) hasHistory(table string) (bool, error) {
+	schema, name := c.schemas().split(table)
+	if schema == "" {
+		schema = "main"
+	}
+
+	rows, err := c.ExecSelect(
+		fmt.Sprintf("SELECT 1 FROM %s.sqlite_master WHERE type = 'table' AND name = ?", escapeIdentifier(schema)),
+		name+HistoryTableSuffix,
+	)
+	if err != nil {
+		return false, err
+	}
+	return len(rows) > 0, nil
+}
+
+// The history triggers name every column, so they are dropped before the table changes and written again
+// for its new columns. Adjust brings the history table along, old versions keep the columns they had
+func (c *Client) alterWithHistory(table string, alter func() error, adjust func(history string) error) error {
+	enabled, err := c.hasHistory(table)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return alter()
+	}
+
+	names := c.schemas()
+	triggerName := triggerNamer(table, names)
+	for _, suffix := range []string{"history_insert", "history_update", "history_delete"} {
+		if err := c.Execute("DROP TRIGGER IF EXISTS " + names.escape(triggerName(suffix))); err != nil {
+			return fmt.Errorf("drop history triggers: %w", err)
+		}
+	}
+
+	err = alter()
+	if err == nil && adjust != nil {
+		if err = adjust(table + HistoryTableSuffix); err != nil {
+			err = fmt.Errorf("alter history table: %w", err)
+		}
+	}
+	// Written again after a failed alter as well, for the columns the table has now
+	if triggerErr := c.createHistoryTriggers(table); triggerErr != nil {
+		err = errors.Join(err, fmt.Errorf("create history triggers: %w", triggerErr))
+	}
+	return err
+}
+
+func (c *Client) createHistoryTriggers(table string) error {
+	columns, err := c.tableColumns(table)
+	if err != nil {
+		return err
+	}
+
+	t := Table{Name: table, PrimaryKey: &PrimaryKey{}, History: &History{}}
+	for _, column := range columns {
+		t.Columns = append(t.Columns, Column{Name: column.Name, Type: column.Type})
+	}
+	sort.SliceStable(columns, func(i, j int) bool { return columns[i].key < columns[j].key })
+	for _, column := range columns {
+		if column.key > 0 {
+			t.PrimaryKey.Columns = append(t.PrimaryKey.Columns, column.Name)
+		}
+	}
+	if len(t.PrimaryKey.Columns) == 0 {
+		return fmt.Errorf("table %q has no primary key", table)
+	}
+
+	names := c.schemas()
+	for _, trigger := range historyTriggers(t, names, triggerNamer(table, names)) {
+		if err := c.Execute(buildTriggerSQL(trigger, names, false)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// New columns get a plain copy, a name that old versions already used is reused
+func (c *Client) addHistoryColumn(history string, column Column) error {
+	columns, err := c.tableColumns(history)
+	if err != nil {
+		return err
+	}
+	if _, ok := findTableColumn(columns, column.Name); ok {
+		return nil
+	}
+
+	plain := Column{Name: column.Name, Type: column.Type}
+	return c.Execute(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", c.schemas().escape(history), buildColumnSQL(plain)))
+}
+
+func (c *Client) renameHistoryColumn(history string, column string, newName string) error {
+	columns, err := c.tableColumns(history)
+	if err != nil {
+		return err
+	}
+	if _, ok := findTableColumn(columns, column); !ok {
+		return nil
+	}
+	if _, ok := findTableColumn(columns, newName); ok {
+		return fmt.Errorf("history table already has a column %q", newName)
+	}
+
+	return c.Execute(fmt.Sprintf(
+		"ALTER TABLE %s RENAME COLUMN %s TO %s",
+		c.schemas().escape(history), escapeIdentifier(column), escapeIdentifier(newName),
+	))
+}
+
+// Only the type is copied, so the history column is rebuilt when the type changes
+func (c *Client) alterHistoryColumn(history string, column Column) error {
+	columns, err := c.tableColumns(history)
+	if err != nil {
+		return err
+	}
+	current, ok := findTableColumn(columns, column.Name)
+	if !ok {
+		return c.addHistoryColumn(history, column)
+	}
+	if strings.EqualFold(string(current.Type), string(column.Type)) {
+		return nil
+	}
+
+	plain := Column{Name: column.Name, Type: column.Type}
+	return c.rebuildTable(history, func(definitions []string) ([]string, error) {
+		for i, definition := range definitions {
+			if name, ok := definitionColumn(definition); ok && strings.EqualFold(name, column.Name) {
+				out := append([]string{}, definitions...)
+				out[i] = buildColumnSQL(plain)
+				return out, nil
+			}
+		}
+		return nil, fmt.Errorf("column %q not found", column.Name)
+	})
+}
+
+type tableColumn struct {
+	Column
+	// Position in the primary key from 1, 0 for other columns
+	key int64
+}
+
+// Hidden columns of virtual tables are left out, generated ones are kept
+func (c *Client) tableColumns(table string) ([]tableColumn, error) {
+	schema, name := c.schemas().split(table)
+	if schema == "" {
+		schema = "main"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rows, err := c.queryLocked(ctx, "SELECT name, type, pk FROM pragma_table_xinfo(?, ?) WHERE hidden <> 1 ORDER BY cid", name, schema)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("table %q not found", table)
+	}
+
+	columns := make([]tableColumn, len(rows))
+	for i, row := range rows {
+		key, _ := row["pk"].(int64)
+		columns[i] = tableColumn{
+			Column: Column{Name: fmt.Sprint(row["name"]), Type: ColumnType(fmt.Sprint(row["type"]))},
+			key:    key,
+		}
+	}
+	return columns, nil
+}
+
+func findTableColumn(columns []tableColumn, name string) (tableColumn, bool) {
+	for _, column := range columns {
+		if strings.EqualFold(column.Name, name) {
+			return column, true
+		}
+	}
+	return tableColumn{}, false
+}
+
+// Rows that existed before history was enabled get an open version starting now
+func (c *Client) backfillHistory(t Table) error {
+	t = withAuditColumns(t)
+	names := c.schemas()
+	_, table := names.split(t.Name)
+	history := historyTable(t, names)
+
+	columns := joinEscapedIdentifiers(columnNames(t))
+	query := fmt.Sprintf(
+		"INSERT INTO %s (%s, %s) SELECT %s, %s FROM %s AS current WHERE NOT EXISTS (SELECT 1 FROM %s AS version WHERE %s AND version.%s IS NULL)",
+		names.escape(history.Name), columns, escapeIdentifier(ColumnValidFrom),
+		columns, nowMillisSQL, names.escape(t.Name),
+		names.escape(history.Name), historyKeyMatch(t, "version", "current"), escapeIdentifier(ColumnValidTo),
+	)
+	if err := c.Execute(query); err != nil {
+		return fmt.Errorf("backfill history of %q: %w", table, err)
+	}
+	return nil
+}
+
+// A plain copy of the columns, constraints of the live table would reject old versions
+func historyTable(t Table, names schemaNames) Table {
+	t = withAuditColumns(t)
+	_, table := names.split(t.Name)
+	keys := primaryKeyColumns(t)
+
+	columns := []Column{{Name: ColumnHistoryID, Type: TypeInteger, PrimaryKey: boolPtr(true), AutoIncrement: boolPtr(true)}}
+	for _, column := range t.Columns {
+		columns = append(columns, Column{Name: column.Name, Type: column.Type})
+	}
+	columns = append(columns,
+		Column{Name: ColumnValidFrom, Type: TypeText, NotNull: boolPtr(true)},
+		Column{Name: ColumnValidTo, Type: TypeText},
+	)
+
+	history := Table{
+		Name:    t.Name + HistoryTableSuffix,
+		Columns: columns,
+		Indexes: []Index{
+			{Name: table + HistoryTableSuffix + "_key", Columns: append(append([]string{}, keys...), ColumnValidFrom)},
+			{Name: table + HistoryTableSuffix + "_" + ColumnValidTo, Columns: []string{ColumnValidTo}, Where: escapeIdentifier(ColumnValidTo) + " IS NOT NULL"},
+		},
+	}
+	if t.History.Retention > 0 {
+		history.Retention = &Retention{Column: ColumnValidTo, MaxAge: t.History.Retention}
+	}
+	return history
+}
+
+func historyTriggers(t Table, names schemaNames, triggerName func(suffix string) string) []Trigger {
+	_, table := names.split(t.Name)
+	history := escapeIdentifier(table + HistoryTableSuffix)
+	columns := columnNames(t)
+
+	values := make([]string, len(columns))
+	for i, column := range columns {
+		values[i] = "NEW." + escapeIdentifier(column)
+	}
+	insert := fmt.Sprintf(
+		"INSERT INTO %s (%s, %s) VALUES (%s, %s);",
+		history, joinEscapedIdentifiers(columns), escapeIdentifier(ColumnValidFrom), strings.Join(values, ", "), nowMillisSQL,
+	)
+	closeVersion := fmt.Sprintf(
+		"UPDATE %s SET %s = %s WHERE %s AND %s IS NULL;",
+		history, escapeIdentifier(ColumnValidTo), nowMillisSQL, historyKeyMatch(t, "", "OLD"), escapeIdentifier(ColumnValidTo),
+	)
+
+	return []Trigger{
+		{Name: triggerName("history_insert"), Table: table, Timing: "AFTER", Event: "INSERT", Body: insert},
+		{Name: triggerName("history_update"), Table: table, Timing: "AFTER", Event: "UPDATE", Body: closeVersion + "\n" + insert},
+		{Name: triggerName("history_delete"), Table: table, Timing: "AFTER", Event: "DELETE", Body: closeVersion},
+	}
+}
+
+func historyKeyMatch(t Table, version string, current string) string {
+	if version != "" {
+		version += "."
+	}
+
+	keys := primaryKeyColumns(t)
+	conditions := make([]string, len(keys))
+	for i, key := range keys {
+		conditions[i] = fmt.Sprintf("%s%s IS %s.%s", version, escapeIdentifier(key), current, escapeIdentifier(key))
+	}
+	return strings.Join(conditions, " AND ")
+}
+
+func primaryKeyColumns(t Table) []string {
+	if t.PrimaryKey != nil {
+		return t.PrimaryKey.Columns
+	}
+	for _, column := range t.Columns {
+		if column.PrimaryKey != nil && *column.PrimaryKey {
+			return []string{column.Name}
+		}
+	}
+	return nil
+}
+
+func columnNames(t Table) []string {
+	names := make([]string, len(t.Columns))
+	for i, column := range t.Columns {
+		names[i] = column.Name
+	}
+	return names
+}
@@ -0,0 +1,532 @@
+package sqlite
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultImportBatchSize = 500
+
+var importTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999-07:00",
+	time.DateTime,
+	"2006-01-02T15:04:05",
+	time.DateOnly,
+}
+
+var (
+	insertIntoRegexp   = regexp.MustCompile(`(?is)^(?:INSERT(?:\s+OR\s+[A-Z]+)?|REPLACE)\s+INTO\s+`)
+	createSchemaRegexp = regexp.MustCompile(`(?is)^CREATE\s+(UNIQUE\s+)?(TABLE|INDEX|TRIGGER)\s+(?:IF\s+NOT\s+EXISTS\s+)?`)
+)
+
+type ImportOptions struct {
+	Header    map[string]string
+	Columns   []string
+	BatchSize int
+	// Commits every batch on its own, so a failed import keeps the batches before it.
+	// By default the whole import is one transaction
+	BatchCommit bool
+	SkipUnknown bool
+}
+
+type importer struct {
+	table   string
+	types   map[string]ColumnType
+	options ImportOptions
+	batch   []map[string]any
+	line    int
+	// Set when the whole import runs in one transaction
+	tx *Tx
+}
+
+func (c *Client) ImportTable(ctx context.Context, table string, r io.Reader, format DataFormat, opts ImportOptions) error {
+	if c == nil || c.db == nil {
+		return errors.New("db client is nil")
+	}
+	if table == "" {
+		return errors.New("table name is empty")
+	}
+
+	if format == FormatSQL {
+		return c.importSQL(ctx, table, r)
+	}
+
+	columns, err := c.DescribeTable(table)
+	if err != nil {
+		return err
+	}
+
+	imp := &importer{table: table, types: make(map[string]ColumnType, len(columns)), options: opts}
+	for _, column := range columns {
+		imp.types[strings.ToLower(column.Name)] = column.Type
+	}
+	if imp.options.BatchSize <= 0 {
+		imp.options.BatchSize = defaultImportBatchSize
+	}
+
+	var read func(ctx context.Context, c *Client, r io.Reader) error
+	switch format {
+	case FormatCSV:
+		read = imp.readCSV
+	case FormatJSONL:
+		read = imp.readJSONL
+	default:
+		return fmt.Errorf("unsupported format: %s", format)
+	}
+
+	run := func(ctx context.Context) error {
+		if err := read(ctx, c, r); err != nil {
+			return fmt.Errorf("import %q line %d: %w", table, imp.line, err)
+		}
+		if err := imp.flush(ctx, c); err != nil {
+			return fmt.Errorf("import %q: %w", table, err)
+		}
+		return nil
+	}
+
+	if opts.BatchCommit {
+		return run(ctx)
+	}
+	return c.transaction(ctx, func(tx *Tx) error {
+		imp.tx = tx
+		return run(tx.ctx)
+	})
+}
+
+// The script may only create and insert into the table, it runs in one transaction
+func (c *Client) importSQL(ctx context.Context, table string, r io.Reader) error {
+	script, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("read sql: %w", err)
+	}
+
+	names := c.schemas()
+	statements := splitSQLStatements(string(script))
+	for i, statement := range statements {
+		var ok bool
+		if statements[i], ok = importStatement(statement, table, names); !ok {
+			return fmt.Errorf("import %q: statement #%d is neither an INSERT into the table nor part of its schema", table, i+1)
+		}
+	}
+
+	return c.transaction(ctx, func(tx *Tx) error {
+		for i, statement := range statements {
+			if err := tx.Execute(statement); err != nil {
+				return fmt.Errorf("import %q statement #%d: %w", table, i+1, err)
+			}
+		}
+		return nil
+	})
+}
+
+func (imp *importer) readCSV(ctx context.Context, c *Client, r io.Reader) error {
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+
+	header := imp.options.Columns
+	if len(header) == 0 {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		imp.line++
+		header = append([]string(nil), record...)
+	}
+
+	columns := make([]string, len(header))
+	for i, field := range header {
+		columns[i] = imp.column(field)
+	}
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		imp.line++
+
+		if len(record) != len(columns) {
+			return fmt.Errorf("got %d fields, want %d", len(record), len(columns))
+		}
+
+		row := make(map[string]any, len(columns))
+		for i, column := range columns {
+			if column == "" {
+				continue
+			}
+			// CSV has no NULL, an empty field only stands for one outside text columns
+			var value any
+			if record[i] != "" || imp.textColumn(column) {
+				value = record[i]
+			}
+			if err := imp.set(row, column, value); err != nil {
+				return err
+			}
+		}
+
+		if err := imp.add(ctx, c, row); err != nil {
+			return err
+		}
+	}
+}
+
+func (imp *importer) readJSONL(ctx context.Context, c *Client, r io.Reader) error {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+
+	for {
+		var object map[string]any
+		err := decoder.Decode(&object)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		imp.line++
+		if err != nil {
+			return err
+		}
+
+		row := make(map[string]any, len(object))
+		for field, value := range object {
+			column := imp.column(field)
+			if column == "" {
+				continue
+			}
+			if err := imp.set(row, column, value); err != nil {
+				return err
+			}
+		}
+
+		if err := imp.add(ctx, c, row); err != nil {
+			return err
+		}
+	}
+}
+
+func (imp *importer) column(field string) string {
+	if mapped, ok := imp.options.Header[field]; ok {
+		return mapped
+	}
+	return field
+}
+
+func (imp *importer) textColumn(column string) bool {
+	typ, ok := imp.types[strings.ToLower(column)]
+	return ok && columnAffinity(typ) == TypeText
+}
+
+func (imp *importer) set(row map[string]any, column string, value any) error {
+	typ, ok := imp.types[strings.ToLower(column)]
+	if !ok {
+		if imp.options.SkipUnknown {
+			return nil
+		}
+		return fmt.Errorf("unknown column %q", column)
+	}
+
+	coerced, err := coerceValue(value, typ)
+	if err != nil {
+		return fmt.Errorf("column %q: %w", column, err)
+	}
+	row[column] = coerced
+	return nil
+}
+
+func (imp *importer) add(ctx context.Context, c *Client, row map[string]any) error {
+	if len(row) == 0 {
+		return nil
+	}
+	imp.batch = append(imp.batch, row)
+	if len(imp.batch) < imp.options.BatchSize {
+		return nil
+	}
+	return imp.flush(ctx, c)
+}
+
+func (imp *importer) flush(ctx context.Context, c *Client) error {
+	if len(imp.batch) == 0 {
+		return nil
+	}
+
+	table := c.schemas().escape(imp.table)
+	insert := func(tx *Tx) error {
+		for _, row := range imp.batch {
+			columns := make([]string, 0, len(row))
+			for column := range row {
+				columns = append(columns, column)
+			}
+			sort.Strings(columns)
+
+			args := make([]any, 0, len(columns))
+			for _, column := range columns {
+				args = append(args, row[column])
+			}
+
+			query := fmt.Sprintf(
+				"INSERT INTO %s (%s) VALUES (%s)",
+				table,
+				joinEscapedIdentifiers(columns),
+				placeholders(len(columns)),
+			)
+			if err := tx.Execute(query, args...); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var err error
+	if imp.tx != nil {
+		err = insert(imp.tx)
+	} else {
+		err = c.transaction(ctx, insert)
+	}
+
+	imp.batch = imp.batch[:0]
+	return err
+}
+
+// Statements without any SQL, such as lone comments, are left out
+func splitSQLStatements(script string) []string {
+	var statements []string
+	start := 0
+	add := func(end int) {
+		if statement := strings.TrimSpace(script[start:end]); trimSQLComments(statement) != "" {
+			statements = append(statements, statement)
+		}
+	}
+	for i := 0; i < len(script); {
+		if next := skipSQLLiteral(script, i); next > i {
+			i = next
+			continue
+		}
+		if script[i] == ';' && !insideTrigger(script[start:i]) {
+			add(i)
+			start = i + 1
+		}
+		i++
+	}
+	add(len(script))
+	return statements
+}
+
+// A trigger body holds statements of its own, the trigger ends with the END of its BEGIN
+func insideTrigger(statement string) bool {
+	words := sqlWords(statement)
+	if len(words) < 2 || !strings.EqualFold(words[0].text, "CREATE") {
+		return false
+	}
+	if !strings.EqualFold(words[1].text, "TRIGGER") && (len(words) < 3 || !strings.EqualFold(words[2].text, "TRIGGER")) {
+		return false
+	}
+
+	// CASE expressions in the body end with END as well
+	open, closed := 0, 0
+	for _, word := range words {
+		switch {
+		case word.quoted:
+		case strings.EqualFold(word.text, "BEGIN"), strings.EqualFold(word.text, "CASE"):
+			open++
+		case strings.EqualFold(word.text, "END"):
+			closed++
+		}
+	}
+	return open == 0 || closed < open
+}
+
+func trimSQLComments(s string) string {
+	for {
+		s = strings.TrimSpace(s)
+		if !strings.HasPrefix(s, "--") && !strings.HasPrefix(s, "/*") {
+			return s
+		}
+		s = s[skipSQLLiteral(s, 0):]
+	}
+}
+
+// INSERTs into the table pass as they are, its own CREATE TABLE, INDEX and TRIGGER statements
+// as written by ExportTable get IF NOT EXISTS so they leave an existing table alone
+func importStatement(statement string, table string, names schemaNames) (string, bool) {
+	statement = trimSQLComments(statement)
+	if loc := insertIntoRegexp.FindStringIndex(statement); loc != nil {
+		parts, _, ok := leadingName(statement[loc[1]:])
+		return statement, ok && namesTable(parts, table, names)
+	}
+
+	loc := createSchemaRegexp.FindStringSubmatchIndex(statement)
+	if loc == nil {
+		return "", false
+	}
+	parts, rest, ok := leadingName(statement[loc[1]:])
+	if !ok {
+		return "", false
+	}
+	if kind := strings.ToUpper(statement[loc[4]:loc[5]]); kind != "TABLE" {
+		// The owner table follows the first ON of the header
+		on := keywordIndex(rest, "ON")
+		if on < 0 {
+			return "", false
+		}
+		if parts, _, ok = leadingName(rest[on+len("ON"):]); !ok {
+			return "", false
+		}
+	}
+	if !namesTable(parts, table, names) {
+		return "", false
+	}
+	return createSchemaRegexp.ReplaceAllString(statement, "CREATE ${1}${2} IF NOT EXISTS "), true
+}
+
+// The possibly qualified name at the start of s and the text after it
+func leadingName(s string) ([]string, string, bool) {
+	rest := strings.TrimLeft(s, " \t\r\n")
+	var parts []string
+	for {
+		end := skipSQLLiteral(rest, 0)
+		if end == 0 {
+			for end < len(rest) && (isWordStart(rest[end]) || rest[end] >= '0' && rest[end] <= '9' || rest[end] == '$') {
+				end++
+			}
+		}
+		words := sqlWords(rest[:end])
+		if end == 0 || len(words) != 1 {
+			return nil, "", false
+		}
+		parts = append(parts, words[0].text)
+
+		rest = strings.TrimLeft(rest[end:], " \t\r\n")
+		if !strings.HasPrefix(rest, ".") || len(parts) == 2 {
+			return parts, rest, true
+		}
+		rest = strings.TrimLeft(rest[1:], " \t\r\n")
+	}
+}
+
+// Position of the first keyword outside literals and comments, -1 when there is none
+func keywordIndex(s string, keyword string) int {
+	for i := 0; i < len(s); {
+		if next := skipSQLLiteral(s, i); next > i {
+			i = next
+			continue
+		}
+		if !isWordStart(s[i]) {
+			i++
+			continue
+		}
+		start := i
+		for i < len(s) && (isWordStart(s[i]) || s[i] >= '0' && s[i] <= '9' || s[i] == '$') {
+			i++
+		}
+		if strings.EqualFold(s[start:i], keyword) {
+			return start
+		}
+	}
+	return -1
+}
+
+// The schema has to be written the same way as in the table name
+func namesTable(parts []string, table string, names schemaNames) bool {
+	schema, name := names.split(table)
+	if len(parts) == 1 {
+		return schema == "" && strings.EqualFold(parts[0], name)
+	}
+	return strings.EqualFold(parts[0], schema) && strings.EqualFold(parts[1], name)
+}
+
+func coerceValue(value any, typ ColumnType) (any, error) {
+	affinity := columnAffinity(typ)
+
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case json.Number:
+		return coerceString(v.String(), affinity)
+	case string:
+		return coerceString(v, affinity)
+	case bool:
+		if affinity == TypeText {
+			return strconv.FormatBool(v), nil
+		}
+		if v {
+			return int64(1), nil
+		}
+		return int64(0), nil
+	case map[string]any, []any:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return string(encoded), nil
+	default:
+		return value, nil
+	}
+}
+
+func coerceString(s string, affinity ColumnType) (any, error) {
+	switch affinity {
+	case TypeInteger:
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i, nil
+		}
+		if b, err := strconv.ParseBool(s); err == nil {
+			return coerceValue(b, affinity)
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil && f == float64(int64(f)) {
+			return int64(f), nil
+		}
+		return nil, fmt.Errorf("invalid integer %q", s)
+	case TypeReal:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid real %q", s)
+		}
+		return f, nil
+	case TypeBlob:
+		return []byte(s), nil
+	case TypeDatetime:
+		for _, layout := range importTimeLayouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				return formatTime(t), nil
+			}
+		}
+		if unix, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return formatTime(time.Unix(unix, 0).UTC()), nil
+		}
+		return nil, fmt.Errorf("invalid datetime %q", s)
+	default:
+		return s, nil
+	}
+}
+
+func columnAffinity(typ ColumnType) ColumnType {
+	upper := strings.ToUpper(string(typ))
+	switch {
+	case upper == string(TypeDatetime) || strings.Contains(upper, "DATE") || strings.Contains(upper, "TIME"):
+		return TypeDatetime
+	case strings.Contains(upper, "INT"):
+		return TypeInteger
+	case strings.Contains(upper, "CHAR") || strings.Contains(upper, "CLOB") || strings.Contains(upper, "TEXT"):
+		return TypeText
+	case strings.Contains(upper, "BLOB"):
+		return TypeBlob
+	case strings.Contains(upper, "REAL") || strings.Contains(upper, "FLOA") || strings.Contains(upper, "DOUB"):
+		return TypeReal
+	default:
+		return ""
+	}
+}
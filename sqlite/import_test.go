@@ -0,0 +1,85 @@
+package sqlite_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/halushko/core-go/sqlite"
+	"github.com/halushko/core-go/sqlite/sqlitetest"
+)
+
+var notesTable = sqlite.Table{
+	Name: "notes",
+	Columns: []sqlite.Column{
+		{Name: "id", Type: sqlite.TypeInteger, PrimaryKey: boolPtr(true)},
+		{Name: "body", Type: sqlite.TypeText},
+		{Name: "rank", Type: sqlite.TypeInteger},
+	},
+	Indexes: []sqlite.Index{{Name: "notes_rank", Columns: []string{"rank"}}},
+}
+
+var noteRows = []map[string]any{
+	{"id": 1, "body": "", "rank": nil},
+	{"id": 2, "body": "it's; fine", "rank": 3},
+}
+
+func TestImportTableRoundTrip(t *testing.T) {
+	for _, format := range []sqlite.DataFormat{sqlite.FormatSQL, sqlite.FormatCSV, sqlite.FormatJSONL} {
+		t.Run(string(format), func(t *testing.T) {
+			source := sqlitetest.New(t, notesTable)
+			sqlitetest.Exec(t, source, `CREATE TRIGGER notes_rank_default AFTER INSERT ON notes WHEN NEW.rank < 0
+				BEGIN UPDATE notes SET rank = CASE WHEN NEW.rank < -10 THEN 0 ELSE 1 END WHERE id = NEW.id; END`)
+			sqlitetest.Seed(t, source, "notes", noteRows...)
+
+			var exported bytes.Buffer
+			if err := source.ExportTable(context.Background(), "notes", &exported, format); err != nil {
+				t.Fatalf("export: %v", err)
+			}
+
+			target := sqlitetest.New(t, notesTable)
+			if err := target.ImportTable(context.Background(), "notes", bytes.NewReader(exported.Bytes()), format, sqlite.ImportOptions{}); err != nil {
+				t.Fatalf("import: %v\n%s", err, exported.String())
+			}
+			sqlitetest.AssertTableRows(t, target, "notes", "id", noteRows...)
+		})
+	}
+}
+
+func TestImportTableSQLCreatesMissingTable(t *testing.T) {
+	source := sqlitetest.New(t, notesTable)
+	sqlitetest.Seed(t, source, "notes", noteRows...)
+
+	var exported bytes.Buffer
+	if err := source.ExportTable(context.Background(), "notes", &exported, sqlite.FormatSQL); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+
+	target := sqlitetest.New(t)
+	if err := target.ImportTable(context.Background(), "notes", &exported, sqlite.FormatSQL, sqlite.ImportOptions{}); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	sqlitetest.AssertTableRows(t, target, "notes", "id", noteRows...)
+}
+
+func TestImportTableSQLRejectsOtherStatements(t *testing.T) {
+	client := sqlitetest.New(t, notesTable, sqlite.Table{
+		Name:    "other",
+		Columns: []sqlite.Column{{Name: "id", Type: sqlite.TypeInteger}},
+	})
+
+	for _, script := range []string{
+		"INSERT INTO notes (id) VALUES (1); DROP TABLE other;",
+		"INSERT INTO other (id) VALUES (1);",
+		"CREATE INDEX other_id ON other (id);",
+		"CREATE TRIGGER notes_wipe AFTER INSERT ON other BEGIN DELETE FROM notes; END;",
+	} {
+		err := client.ImportTable(context.Background(), "notes", strings.NewReader(script), sqlite.FormatSQL, sqlite.ImportOptions{})
+		if err == nil {
+			t.Errorf("script was imported: %s", script)
+		}
+	}
+	sqlitetest.AssertRowCount(t, client, "notes", 0)
+	sqlitetest.AssertRowCount(t, client, "other", 0)
+}
@@ -0,0 +1,168 @@
+package sqlite
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+func (c *Client) DropIndex(name string) error {
+	if name == "" {
+		return errors.New("index name is empty")
+	}
+
+	return c.Execute(fmt.Sprintf("DROP INDEX IF EXISTS %s", c.schemas().escape(name)))
+}
+
+func (c *Client) Reindex(name string) error {
+	if name == "" {
+		return c.Execute("REINDEX")
+	}
+	return c.Execute("REINDEX " + c.schemas().escape(name))
+}
+
+func (c *Client) Analyze(name string) error {
+	if name == "" {
+		return c.Execute("ANALYZE")
+	}
+	return c.Execute("ANALYZE " + c.schemas().escape(name))
+}
+
+func (c *Client) ListIndexes(table string) ([]IndexInfo, error) {
+	if table == "" {
+		return nil, errors.New("table name is empty")
+	}
+
+	schema, name := c.schemas().split(table)
+	prefix := ""
+	if schema != "" {
+		prefix = escapeIdentifier(schema) + "."
+	}
+
+	list, err := c.ExecSelect(fmt.Sprintf("PRAGMA %sindex_list(%s)", prefix, escapeIdentifier(name)))
+	if err != nil {
+		return nil, fmt.Errorf("list indexes of %q: %w", table, err)
+	}
+
+	stats, err := c.indexStats(prefix, name)
+	if err != nil {
+		return nil, err
+	}
+
+	definitions, err := c.ExecSelect(
+		fmt.Sprintf("SELECT name, sql FROM %ssqlite_master WHERE type = 'index' AND tbl_name = ?", prefix),
+		name,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list indexes of %q: %w", table, err)
+	}
+	sqlByName := make(map[string]string, len(definitions))
+	for _, row := range definitions {
+		if statement, ok := row["sql"].(string); ok {
+			sqlByName[fmt.Sprint(row["name"])] = statement
+		}
+	}
+
+	out := make([]IndexInfo, 0, len(list))
+	for i := len(list) - 1; i >= 0; i-- {
+		row := list[i]
+		info := IndexInfo{
+			Name:    fmt.Sprint(row["name"]),
+			Table:   table,
+			Origin:  fmt.Sprint(row["origin"]),
+			Unique:  row["unique"] == int64(1),
+			Partial: row["partial"] == int64(1),
+		}
+		info.SQL = sqlByName[info.Name]
+
+		keys, err := c.ExecSelect(fmt.Sprintf("PRAGMA %sindex_info(%s)", prefix, escapeIdentifier(info.Name)))
+		if err != nil {
+			return nil, fmt.Errorf("describe index %q: %w", info.Name, err)
+		}
+		for _, key := range keys {
+			if column, ok := key["name"].(string); ok {
+				info.Columns = append(info.Columns, column)
+			} else {
+				info.Columns = append(info.Columns, "<expr>")
+			}
+		}
+
+		info.Stat = stats[info.Name]
+		info.Rows, info.RowsPerKey = parseIndexStat(info.Stat)
+		info.Hint = indexHint(info)
+
+		out = append(out, info)
+	}
+
+	return out, nil
+}
+
+func (c *Client) indexStats(prefix string, table string) (map[string]string, error) {
+	exists, err := c.ExecSelect(fmt.Sprintf("SELECT 1 FROM %ssqlite_master WHERE type = 'table' AND name = 'sqlite_stat1'", prefix))
+	if err != nil {
+		return nil, fmt.Errorf("read index stats: %w", err)
+	}
+
+	stats := map[string]string{}
+	if len(exists) == 0 {
+		return stats, nil
+	}
+
+	rows, err := c.ExecSelect(fmt.Sprintf("SELECT idx, stat FROM %ssqlite_stat1 WHERE tbl = ?", prefix), table)
+	if err != nil {
+		return nil, fmt.Errorf("read index stats: %w", err)
+	}
+	for _, row := range rows {
+		if idx, ok := row["idx"].(string); ok {
+			stats[idx] = fmt.Sprint(row["stat"])
+		}
+	}
+	return stats, nil
+}
+
+func parseIndexStat(stat string) (int64, []int64) {
+	fields := strings.Fields(stat)
+	if len(fields) == 0 {
+		return 0, nil
+	}
+
+	rows, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return 0, nil
+	}
+
+	var perKey []int64
+	for _, field := range fields[1:] {
+		n, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			// Trailing options such as "unordered" or "sz=N"
+			break
+		}
+		perKey = append(perKey, n)
+	}
+	return rows, perKey
+}
+
+func indexHint(info IndexInfo) string {
+	switch {
+	case info.Stat == "":
+		return "no statistics, run Analyze"
+	case info.Rows == 0:
+		return "table is empty"
+	case len(info.RowsPerKey) == 0:
+		return ""
+	}
+
+	first := info.RowsPerKey[0]
+	switch {
+	case info.Unique || first <= 1:
+		return "highly selective"
+	case first*2 >= info.Rows:
+		return fmt.Sprintf("low selectivity: about %d of %d rows per leading key, the planner will rarely use it", first, info.Rows)
+	case first*10 >= info.Rows:
+		return fmt.Sprintf("moderate selectivity: about %d rows per leading key", first)
+	default:
+		return fmt.Sprintf("selective: about %d rows per leading key", first)
+	}
+}
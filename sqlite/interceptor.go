@@ -0,0 +1,69 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+type QueryKind string
+
+const (
+	QueryExecute     QueryKind = "execute"
+	QuerySelect      QueryKind = "select"
+	QueryTransaction QueryKind = "transaction"
+)
+
+// SQL and Args may be rewritten before next is called, Rows and Duration are filled once it returns
+type Query struct {
+	Kind          QueryKind
+	SQL           string
+	Args          []any
+	InTransaction bool
+	Started       time.Time
+	Duration      time.Duration
+	Rows          int64
+}
+
+type QueryHandler func(ctx context.Context, q *Query) error
+
+// Returning an error without calling next rejects the query.
+// Only the library's own metadata reads skip the chain: sqlite_master lookups, EXPLAIN for the cache
+// and scan warnings, and the read-only pragmas of maintenance and stats
+type Interceptor func(ctx context.Context, q *Query, next QueryHandler) error
+
+// The first interceptor added is the outermost one
+func (c *Client) Use(interceptor Interceptor) error {
+	if c == nil || c.db == nil {
+		return errors.New("db client is nil")
+	}
+	if interceptor == nil {
+		return errors.New("interceptor is nil")
+	}
+
+	c.interceptorsMutex.Lock()
+	defer c.interceptorsMutex.Unlock()
+
+	c.interceptors = append(c.interceptors, interceptor)
+	return nil
+}
+
+func (c *Client) intercept(ctx context.Context, q *Query, run QueryHandler) error {
+	c.interceptorsMutex.RLock()
+	chain := c.interceptors
+	c.interceptorsMutex.RUnlock()
+
+	handler := func(ctx context.Context, q *Query) error {
+		q.Started = time.Now()
+		err := run(ctx, q)
+		q.Duration = time.Since(q.Started)
+		return err
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		interceptor, next := chain[i], handler
+		handler = func(ctx context.Context, q *Query) error {
+			return interceptor(ctx, q, next)
+		}
+	}
+	return handler(ctx, q)
+}
@@ -0,0 +1,103 @@
+package sqlite
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"time"
+)
+
+var (
+	_ QueryDBI       = (*Client)(nil)
+	_ InterceptorDBI = (*Client)(nil)
+	_ DataDBI        = (*Client)(nil)
+	_ SchemaDBI      = (*Client)(nil)
+	_ SoftDeleteDBI  = (*Client)(nil)
+	_ HistoryDBI     = (*Client)(nil)
+	_ SpatialDBI     = (*Client)(nil)
+	_ CacheDBI       = (*Client)(nil)
+	_ MaintenanceDBI = (*Client)(nil)
+	_ AttachDBI      = (*Client)(nil)
+)
+
+type QueryDBI interface {
+	ExecSelectResult(query string, args ...any) (Result, error)
+	ExecSelectResultNamed(query string, params map[string]any) (Result, error)
+	ExecSelectResultWithTimeout(query string, timeout time.Duration, args ...any) (Result, error)
+	SelectPage(query string, orderBy []OrderKey, cursor string, limit int, args ...any) (Page, error)
+	SelectPageNamed(query string, orderBy []OrderKey, cursor string, limit int, params map[string]any) (Page, error)
+}
+
+type InterceptorDBI interface {
+	Use(interceptor Interceptor) error
+}
+
+type DataDBI interface {
+	LoadFixtures(fsys fs.FS, files ...string) error
+	LoadFixturesWithMode(fsys fs.FS, mode FixtureMode, files ...string) error
+	ExportTable(ctx context.Context, table string, w io.Writer, format DataFormat) error
+	ExportQuery(ctx context.Context, w io.Writer, format DataFormat, query string, args ...any) error
+	ImportTable(ctx context.Context, table string, r io.Reader, format DataFormat, opts ImportOptions) error
+	Dump(w io.Writer) error
+}
+
+type SchemaDBI interface {
+	DescribeTable(name string) ([]Column, error)
+	RenameTable(name string, newName string) error
+	AddColumn(table string, column Column) error
+	RenameColumn(table string, column string, newName string) error
+	DropColumn(table string, column string) error
+	AlterColumn(table string, column Column) error
+	AddForeignKey(table string, fk ForeignKey) error
+	ListIndexes(table string) ([]IndexInfo, error)
+	DropIndex(name string) error
+	Reindex(name string) error
+	Analyze(name string) error
+	SchemaSQL() (string, error)
+	ApplySchema(s Schema) error
+	PlanSchema(s Schema, opts SyncOptions) ([]SchemaChange, error)
+	SyncSchema(s Schema, opts SyncOptions) ([]SchemaChange, error)
+}
+
+type SoftDeleteDBI interface {
+	SelectLive(table string, where string, args ...any) ([]map[string]any, error)
+	SelectWithDeleted(table string, where string, args ...any) ([]map[string]any, error)
+	SelectDeleted(table string, where string, args ...any) ([]map[string]any, error)
+	PurgeDeleted(table string, retention time.Duration) (int64, error)
+}
+
+type HistoryDBI interface {
+	AsOf(table string, at time.Time) ([]map[string]any, error)
+	History(table string, pk ...any) ([]map[string]any, error)
+}
+
+type SpatialDBI interface {
+	CreateSpatialTable(s SpatialTable) error
+	WithinBox(s SpatialTable, box BoundingBox) ([]map[string]any, error)
+	Nearest(s SpatialTable, lat float64, lon float64, limit int, maxDistance float64) ([]map[string]any, error)
+}
+
+// Query cache and the query plan checks of dev mode
+type CacheDBI interface {
+	EnableCache(opts CacheOptions) error
+	DisableCache()
+	CacheStats() CacheStats
+	InvalidateCache(tables ...string)
+	Explain(query string, args ...any) ([]*PlanNode, error)
+	SetDevMode(scanThreshold int64)
+}
+
+type MaintenanceDBI interface {
+	SetRetention(table string, rule Retention) error
+	RemoveRetention(table string)
+	PurgeExpired(ctx context.Context) (map[string]int64, error)
+	StartMaintenance(opts MaintenanceOptions) error
+	StopMaintenance()
+	RunMaintenance(ctx context.Context, task MaintenanceTask) (MaintenanceReport, error)
+}
+
+type AttachDBI interface {
+	Attach(alias string, otherDbName string, readOnly bool) error
+	Detach(alias string) error
+	QuoteName(name string) string
+}
@@ -0,0 +1,285 @@
+package sqlite
+
+import (
+	"context"
+	external "database/sql"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	kvTable = "kv_store"
+	kvLive  = "(expires_at IS NULL OR expires_at > " + nowMillisSQL + ")"
+
+	kvPurgeInterval  = time.Minute
+	kvPurgeBatchSize = 500
+)
+
+type KV struct {
+	client    *Client
+	namespace string
+	// Unix nanoseconds of the last opportunistic purge
+	purged atomic.Int64
+}
+
+type KVEntry struct {
+	Key       string
+	Value     []byte
+	ExpiresAt time.Time
+}
+
+func NewKV(client *Client, namespace string) (*KV, error) {
+	if client == nil || client.db == nil {
+		return nil, errors.New("db client is nil")
+	}
+	if namespace == "" {
+		return nil, errors.New("kv namespace is empty")
+	}
+
+	err := client.CreateTable(Table{
+		Name: kvTable,
+		Columns: []Column{
+			{Name: "namespace", Type: TypeText, NotNull: boolPtr(true)},
+			{Name: "key", Type: TypeText, NotNull: boolPtr(true)},
+			{Name: "value", Type: TypeBlob},
+			{Name: "expires_at", Type: TypeText},
+		},
+		PrimaryKey: &PrimaryKey{Columns: []string{"namespace", "key"}},
+		Indexes: []Index{
+			{Name: kvTable + "_expires_at", Columns: []string{"expires_at"}, Where: "expires_at IS NOT NULL"},
+		},
+		// Maintenance purges expired keys as well, Set and Scan do it in batches when it does not run
+		Retention: &Retention{Column: "expires_at", MaxAge: time.Second},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create kv store: %w", err)
+	}
+
+	return &KV{client: client, namespace: namespace}, nil
+}
+
+func (kv *KV) Get(key string, dest any) error {
+	entry, err := kv.GetEntry(key)
+	if err != nil {
+		return err
+	}
+	return entry.Decode(dest)
+}
+
+func (kv *KV) GetEntry(key string) (KVEntry, error) {
+	rows, err := kv.query(
+		"SELECT key, value, expires_at FROM "+kvTable+" WHERE namespace = ? AND key = ? AND "+kvLive,
+		kv.namespace, key,
+	)
+	if err != nil {
+		return KVEntry{}, fmt.Errorf("kv get %q: %w", key, err)
+	}
+	if len(rows) == 0 {
+		return KVEntry{}, ErrNotFound
+	}
+	return kvEntry(rows[0])
+}
+
+func (kv *KV) Set(key string, value any, ttl time.Duration) error {
+	encoded, expires, err := kvEncode(value, ttl)
+	if err != nil {
+		return fmt.Errorf("kv set %q: %w", key, err)
+	}
+
+	_, err = kv.exec(
+		"INSERT INTO "+kvTable+" (namespace, key, value, expires_at) VALUES (?, ?, ?, ?)"+
+			" ON CONFLICT (namespace, key) DO UPDATE SET value = excluded.value, expires_at = excluded.expires_at",
+		kv.namespace, key, encoded, expires,
+	)
+	if err != nil {
+		return fmt.Errorf("kv set %q: %w", key, err)
+	}
+
+	kv.purgeExpired()
+	return nil
+}
+
+func (kv *KV) Delete(key string) error {
+	if _, err := kv.exec("DELETE FROM "+kvTable+" WHERE namespace = ? AND key = ?", kv.namespace, key); err != nil {
+		return fmt.Errorf("kv delete %q: %w", key, err)
+	}
+	return nil
+}
+
+// A nil old value only swaps when the key is missing or expired
+func (kv *KV) CompareAndSwap(key string, old any, value any, ttl time.Duration) (bool, error) {
+	encoded, expires, err := kvEncode(value, ttl)
+	if err != nil {
+		return false, fmt.Errorf("kv swap %q: %w", key, err)
+	}
+
+	var result external.Result
+	if old == nil {
+		result, err = kv.exec(
+			"INSERT INTO "+kvTable+" (namespace, key, value, expires_at) VALUES (?, ?, ?, ?)"+
+				" ON CONFLICT (namespace, key) DO UPDATE SET value = excluded.value, expires_at = excluded.expires_at"+
+				" WHERE NOT "+kvLive,
+			kv.namespace, key, encoded, expires,
+		)
+	} else {
+		var previous []byte
+		if previous, _, err = kvEncode(old, 0); err != nil {
+			return false, fmt.Errorf("kv swap %q: %w", key, err)
+		}
+		result, err = kv.exec(
+			"UPDATE "+kvTable+" SET value = ?, expires_at = ? WHERE namespace = ? AND key = ? AND value = ? AND "+kvLive,
+			encoded, expires, kv.namespace, key, previous,
+		)
+	}
+	if err != nil {
+		return false, fmt.Errorf("kv swap %q: %w", key, err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("kv swap %q: %w", key, err)
+	}
+	return affected > 0, nil
+}
+
+// Keeps the expiry of a live key, a missing or expired key starts from zero without expiry
+func (kv *KV) Increment(key string, delta int64) (int64, error) {
+	rows, err := kv.query(
+		"INSERT INTO "+kvTable+" (namespace, key, value, expires_at) VALUES (?, ?, CAST(CAST(? AS TEXT) AS BLOB), NULL)"+
+			" ON CONFLICT (namespace, key) DO UPDATE SET"+
+			" value = CAST(CAST(CASE WHEN "+kvLive+" THEN CAST(CAST(value AS TEXT) AS INTEGER) ELSE 0 END + ? AS TEXT) AS BLOB),"+
+			" expires_at = CASE WHEN "+kvLive+" THEN expires_at END"+
+			" WHERE NOT "+kvLive+" OR CAST(CAST(CAST(value AS TEXT) AS INTEGER) AS TEXT) = CAST(value AS TEXT)"+
+			" RETURNING CAST(CAST(value AS TEXT) AS INTEGER) AS value",
+		kv.namespace, key, delta, delta,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("kv increment %q: %w", key, err)
+	}
+	if len(rows) == 0 {
+		return 0, fmt.Errorf("kv increment %q: value is not an integer", key)
+	}
+
+	value, _ := rows[0]["value"].(int64)
+	return value, nil
+}
+
+func (kv *KV) Scan(prefix string) ([]KVEntry, error) {
+	kv.purgeExpired()
+
+	query := "SELECT key, value, expires_at FROM " + kvTable + " WHERE namespace = ? AND " + kvLive
+	args := []any{kv.namespace}
+	if prefix != "" {
+		query += " AND key >= ?"
+		args = append(args, prefix)
+		if end, ok := prefixEnd(prefix); ok {
+			query += " AND key < ?"
+			args = append(args, end)
+		}
+	}
+
+	rows, err := kv.query(query+" ORDER BY key", args...)
+	if err != nil {
+		return nil, fmt.Errorf("kv scan %q: %w", prefix, err)
+	}
+
+	entries := make([]KVEntry, 0, len(rows))
+	for _, row := range rows {
+		entry, err := kvEntry(row)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func (e KVEntry) Decode(dest any) error {
+	if err := decodeValue(e.Value, dest); err != nil {
+		return fmt.Errorf("kv decode %q: %w", e.Key, err)
+	}
+	return nil
+}
+
+// At most one batch per interval, so expired keys do not pile up without maintenance
+func (kv *KV) purgeExpired() {
+	last := kv.purged.Load()
+	now := time.Now().UnixNano()
+	if now-last < int64(kvPurgeInterval) || !kv.purged.CompareAndSwap(last, now) {
+		return
+	}
+
+	_, err := kv.exec(fmt.Sprintf(
+		"DELETE FROM %s WHERE rowid IN (SELECT rowid FROM %s WHERE expires_at IS NOT NULL AND NOT %s LIMIT %d)",
+		kvTable, kvTable, kvLive, kvPurgeBatchSize,
+	))
+	if err != nil {
+		log.Warnf("KV store can not purge expired keys: %v", err)
+	}
+}
+
+// Reads skip the query cache, liveness depends on the current time
+func (kv *KV) query(query string, args ...any) ([]map[string]any, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return kv.client.queryLocked(ctx, query, args...)
+}
+
+func (kv *KV) exec(query string, args ...any) (external.Result, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return kv.client.executeContext(ctx, query, args...)
+}
+
+func kvEncode(value any, ttl time.Duration) ([]byte, any, error) {
+	if ttl < 0 {
+		return nil, nil, fmt.Errorf("invalid ttl: %s", ttl)
+	}
+
+	encoded, err := encodeValue(value)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var expires any
+	if ttl > 0 {
+		expires = time.Now().UTC().Add(ttl).Format(millisTimeLayout)
+	}
+	return encoded, expires, nil
+}
+
+func kvEntry(row map[string]any) (KVEntry, error) {
+	entry := KVEntry{Key: fmt.Sprint(row["key"])}
+	switch v := row["value"].(type) {
+	case []byte:
+		entry.Value = v
+	case string:
+		entry.Value = []byte(v)
+	}
+
+	if expires, ok := row["expires_at"].(string); ok {
+		t, err := time.Parse(millisTimeLayout, expires)
+		if err != nil {
+			return KVEntry{}, fmt.Errorf("kv entry %q: invalid expiry %q", entry.Key, expires)
+		}
+		entry.ExpiresAt = t
+	}
+	return entry, nil
+}
+
+func prefixEnd(prefix string) (string, bool) {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return string(end[:i+1]), true
+		}
+	}
+	return "", false
+}
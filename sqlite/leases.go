@@ -0,0 +1,232 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	engine "modernc.org/sqlite"
+)
+
+const (
+	leaseTable = "leases"
+
+	sqliteBusy     = 5
+	leaseRetries   = 10
+	leaseRetryWait = 50 * time.Millisecond
+)
+
+var (
+	ErrLeaseHeld = errors.New("lease is held by another holder")
+	ErrLeaseLost = errors.New("lease expired or was taken by another holder")
+)
+
+type Lease struct {
+	client    *Client
+	ttl       time.Duration
+	Name      string
+	Holder    string
+	Token     int64
+	ExpiresAt time.Time
+}
+
+// The token grows every time the lease changes hands, a write fenced by a smaller token comes from a stale holder
+func AcquireLease(client *Client, name string, holder string, ttl time.Duration) (*Lease, error) {
+	if client == nil || client.db == nil {
+		return nil, errors.New("db client is nil")
+	}
+	if name == "" {
+		return nil, errors.New("lease name is empty")
+	}
+	if holder == "" {
+		return nil, errors.New("lease holder is empty")
+	}
+	if ttl <= 0 {
+		return nil, fmt.Errorf("invalid lease ttl: %s", ttl)
+	}
+
+	err := client.CreateTable(Table{
+		Name: leaseTable,
+		Columns: []Column{
+			{Name: "name", Type: TypeText, PrimaryKey: boolPtr(true)},
+			{Name: "holder", Type: TypeText, NotNull: boolPtr(true)},
+			{Name: "token", Type: TypeInteger, NotNull: boolPtr(true)},
+			{Name: "expires_at", Type: TypeText, NotNull: boolPtr(true)},
+		},
+		Timestamps: boolPtr(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create lease table: %w", err)
+	}
+
+	lease := &Lease{client: client, ttl: ttl, Name: name, Holder: holder}
+	now := time.Now()
+	expires := now.Add(ttl)
+
+	var rows []map[string]any
+	err = lease.retry(func(ctx context.Context) error {
+		var err error
+		rows, err = client.queryLocked(ctx,
+			"INSERT INTO "+leaseTable+" (name, holder, token, expires_at) VALUES (?, ?, 1, ?)"+
+				" ON CONFLICT (name) DO UPDATE SET"+
+				" token = CASE WHEN holder = excluded.holder AND expires_at > ? THEN token ELSE token + 1 END,"+
+				" holder = excluded.holder, expires_at = excluded.expires_at"+
+				" WHERE holder = excluded.holder OR expires_at <= ?"+
+				" RETURNING token",
+			name, holder, queueTime(expires), queueTime(now), queueTime(now),
+		)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("acquire lease %q: %w", name, err)
+	}
+	if len(rows) == 0 {
+		return nil, ErrLeaseHeld
+	}
+
+	lease.Token, _ = rows[0]["token"].(int64)
+	lease.ExpiresAt = expires
+	return lease, nil
+}
+
+func (l *Lease) Renew() error {
+	if l == nil || l.client == nil {
+		return errors.New("lease is nil")
+	}
+
+	now := time.Now()
+	expires := now.Add(l.ttl)
+	if err := l.fenced("UPDATE "+leaseTable+" SET expires_at = ?", queueTime(expires)); err != nil {
+		return fmt.Errorf("renew lease %q: %w", l.Name, err)
+	}
+	l.ExpiresAt = expires
+	return nil
+}
+
+// Expires the lease but keeps its row, the token must keep growing for the next holder
+func (l *Lease) Release() error {
+	if l == nil || l.client == nil {
+		return errors.New("lease is nil")
+	}
+
+	now := time.Now()
+	if err := l.fenced("UPDATE "+leaseTable+" SET expires_at = ?", queueTime(now)); err != nil {
+		return fmt.Errorf("release lease %q: %w", l.Name, err)
+	}
+	l.ExpiresAt = now
+	return nil
+}
+
+func (l *Lease) Check() error {
+	if l == nil || l.client == nil {
+		return errors.New("lease is nil")
+	}
+	if err := l.fenced("SELECT 1 AS held FROM " + leaseTable); err != nil {
+		return fmt.Errorf("check lease %q: %w", l.Name, err)
+	}
+	return nil
+}
+
+// Runs fn with a context that is cancelled as soon as the lease can no longer be renewed
+func (l *Lease) RunWhileLeader(ctx context.Context, fn func(ctx context.Context) error) error {
+	if l == nil || l.client == nil {
+		return errors.New("lease is nil")
+	}
+	if fn == nil {
+		return errors.New("leader function is nil")
+	}
+
+	leaderCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- fn(leaderCtx)
+	}()
+
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case err := <-done:
+			if releaseErr := l.Release(); releaseErr != nil && !errors.Is(releaseErr, ErrLeaseLost) {
+				log.Warnf("Lease %q can not be released: %v", l.Name, releaseErr)
+			}
+			return err
+		case <-ticker.C:
+			err := l.Renew()
+			if err == nil {
+				continue
+			}
+			// A transient failure is retried until the lease really runs out
+			if !errors.Is(err, ErrLeaseLost) && time.Now().Before(l.ExpiresAt) {
+				log.Warnf("Lease %q renewal failed, retrying: %v", l.Name, err)
+				continue
+			}
+			log.Warnf("Lease %q is lost by %s: %v", l.Name, l.Holder, err)
+			cancel()
+			<-done
+			return ErrLeaseLost
+		}
+	}
+}
+
+func (l *Lease) fenced(statement string, args ...any) error {
+	return l.retry(func(ctx context.Context) error {
+		now := queueTime(time.Now())
+
+		query := statement + " WHERE name = ? AND holder = ? AND token = ? AND expires_at > ?"
+		if strings.HasPrefix(statement, "UPDATE") {
+			query += " RETURNING 1 AS held"
+		}
+
+		rows, err := l.client.queryLocked(ctx, query, append(args, l.Name, l.Holder, l.Token, now)...)
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return ErrLeaseLost
+		}
+		return nil
+	})
+}
+
+// Replicas share the file from separate processes, a busy database is waited for instead of failing
+func (l *Lease) retry(fn func(ctx context.Context) error) error {
+	var err error
+	for attempt := 0; attempt < leaseRetries; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), l.ttl)
+		err = fn(ctx)
+		cancel()
+		if !isBusy(err) {
+			return err
+		}
+		time.Sleep(leaseRetryWait * time.Duration(attempt+1))
+	}
+	return err
+}
+
+func (c *Client) queryLocked(ctx context.Context, query string, args ...any) ([]map[string]any, error) {
+	var out []map[string]any
+	err := c.intercept(ctx, &Query{Kind: QuerySelect, SQL: query, Args: args}, func(ctx context.Context, q *Query) error {
+		if c.mutex != nil {
+			c.mutex.Lock()
+			defer c.mutex.Unlock()
+		}
+
+		var err error
+		out, err = c.queryRows(ctx, q.SQL, q.Args...)
+		q.Rows = int64(len(out))
+		return err
+	})
+	return out, err
+}
+
+func isBusy(err error) bool {
+	var sqliteErr *engine.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code()&0xff == sqliteBusy
+}
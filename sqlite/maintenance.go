@@ -0,0 +1,416 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type MaintenanceTask string
+
+const (
+	MaintenanceOptimize          MaintenanceTask = "optimize"
+	MaintenanceIncrementalVacuum MaintenanceTask = "incremental_vacuum"
+	MaintenanceCheckpoint        MaintenanceTask = "wal_checkpoint"
+	MaintenanceQuickCheck        MaintenanceTask = "quick_check"
+	MaintenanceIntegrityCheck    MaintenanceTask = "integrity_check"
+	MaintenanceRetention         MaintenanceTask = "retention"
+)
+
+const defaultMaintenanceTimeout = 5 * time.Minute
+
+type MaintenanceOptions struct {
+	Optimize          time.Duration
+	IncrementalVacuum time.Duration
+	Checkpoint        time.Duration
+	QuickCheck        time.Duration
+	IntegrityCheck    time.Duration
+	Retention         time.Duration
+
+	// Pages freed per incremental vacuum run, 0 frees all
+	VacuumPages int
+	Timeout     time.Duration
+}
+
+type DatabaseSize struct {
+	Pages     int64
+	PageSize  int64
+	FreePages int64
+	Bytes     int64
+	WALBytes  int64
+}
+
+type MaintenanceReport struct {
+	Task     MaintenanceTask
+	Started  time.Time
+	Duration time.Duration
+	Skipped  bool
+	Reason   string
+	Problems []string
+	Purged   map[string]int64
+	Before   DatabaseSize
+	After    DatabaseSize
+}
+
+type maintenance struct {
+	client  *Client
+	options MaintenanceOptions
+	running sync.Mutex
+	stop    chan struct{}
+	done    sync.WaitGroup
+}
+
+func (c *Client) StartMaintenance(opts MaintenanceOptions) error {
+	if c == nil || c.db == nil {
+		return errors.New("db client is nil")
+	}
+
+	intervals := opts.intervals()
+	if len(intervals) == 0 {
+		return errors.New("no maintenance task is scheduled")
+	}
+	for task, interval := range intervals {
+		if interval < 0 {
+			return fmt.Errorf("invalid %s interval: %s", task, interval)
+		}
+	}
+	if opts.VacuumPages < 0 {
+		return fmt.Errorf("invalid vacuum pages: %d", opts.VacuumPages)
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultMaintenanceTimeout
+	}
+
+	c.maintenanceMutex.Lock()
+	defer c.maintenanceMutex.Unlock()
+
+	if c.maintenance != nil {
+		return errors.New("maintenance is already running")
+	}
+
+	m := &maintenance{client: c, options: opts, stop: make(chan struct{})}
+	for task, interval := range intervals {
+		m.done.Add(1)
+		go m.schedule(task, interval)
+	}
+	c.maintenance = m
+
+	log.Infof("SQLite maintenance started: %s", opts)
+	return nil
+}
+
+func (c *Client) StopMaintenance() {
+	if c == nil {
+		return
+	}
+
+	c.maintenanceMutex.Lock()
+	m := c.maintenance
+	c.maintenance = nil
+	c.maintenanceMutex.Unlock()
+
+	if m == nil {
+		return
+	}
+	close(m.stop)
+	m.done.Wait()
+	log.Info("SQLite maintenance stopped")
+}
+
+func (c *Client) RunMaintenance(ctx context.Context, task MaintenanceTask) (MaintenanceReport, error) {
+	if c == nil || c.db == nil {
+		return MaintenanceReport{}, errors.New("db client is nil")
+	}
+	if task == MaintenanceRetention {
+		return c.runRetention(ctx)
+	}
+
+	if c.mutex != nil {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+	}
+	return c.runMaintenance(ctx, task, 0)
+}
+
+func (o MaintenanceOptions) intervals() map[MaintenanceTask]time.Duration {
+	intervals := map[MaintenanceTask]time.Duration{}
+	for task, interval := range map[MaintenanceTask]time.Duration{
+		MaintenanceOptimize:          o.Optimize,
+		MaintenanceIncrementalVacuum: o.IncrementalVacuum,
+		MaintenanceCheckpoint:        o.Checkpoint,
+		MaintenanceQuickCheck:        o.QuickCheck,
+		MaintenanceIntegrityCheck:    o.IntegrityCheck,
+		MaintenanceRetention:         o.Retention,
+	} {
+		if interval != 0 {
+			intervals[task] = interval
+		}
+	}
+	return intervals
+}
+
+func (o MaintenanceOptions) String() string {
+	var parts []string
+	for _, task := range []MaintenanceTask{
+		MaintenanceOptimize,
+		MaintenanceIncrementalVacuum,
+		MaintenanceCheckpoint,
+		MaintenanceQuickCheck,
+		MaintenanceIntegrityCheck,
+		MaintenanceRetention,
+	} {
+		if interval, ok := o.intervals()[task]; ok {
+			parts = append(parts, fmt.Sprintf("%s every %s", task, interval))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (m *maintenance) schedule(task MaintenanceTask, interval time.Duration) {
+	defer m.done.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			m.run(task)
+		}
+	}
+}
+
+func (m *maintenance) run(task MaintenanceTask) {
+	c := m.client
+
+	// Tasks never queue up behind each other or behind the application
+	if !m.running.TryLock() {
+		log.Debugf("SQLite maintenance %s skipped: another task is running", task)
+		return
+	}
+	defer m.running.Unlock()
+
+	// Purging takes the client lock per batch instead of for the whole run
+	if task == MaintenanceRetention {
+		if c.busy() {
+			log.Debugf("SQLite maintenance %s skipped: database is busy", task)
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), m.options.Timeout)
+		defer cancel()
+
+		report, err := c.runRetention(ctx)
+		if err != nil {
+			log.Errorf("SQLite maintenance %s failed: %v", task, err)
+			return
+		}
+		report.log()
+		return
+	}
+
+	if c.mutex != nil {
+		if !c.mutex.TryLock() {
+			log.Debugf("SQLite maintenance %s skipped: database is busy", task)
+			return
+		}
+		defer c.mutex.Unlock()
+	}
+	if c.busy() {
+		log.Debugf("SQLite maintenance %s skipped: database is busy", task)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.options.Timeout)
+	defer cancel()
+
+	report, err := c.runMaintenance(ctx, task, m.options.VacuumPages)
+	if err != nil {
+		log.Errorf("SQLite maintenance %s failed: %v", task, err)
+		return
+	}
+	report.log()
+}
+
+func (c *Client) busy() bool {
+	inUse := c.db.Stats().InUse
+	if c.keeper != nil {
+		inUse--
+	}
+	return inUse > 0
+}
+
+func (c *Client) runMaintenance(ctx context.Context, task MaintenanceTask, vacuumPages int) (MaintenanceReport, error) {
+	report := MaintenanceReport{Task: task, Started: time.Now()}
+
+	before, err := c.databaseSize(ctx)
+	if err != nil {
+		return report, err
+	}
+	report.Before = before
+
+	switch task {
+	case MaintenanceOptimize:
+		err = c.drain(ctx, "PRAGMA optimize")
+	case MaintenanceIncrementalVacuum:
+		err = c.incrementalVacuum(ctx, vacuumPages, &report)
+	case MaintenanceCheckpoint:
+		err = c.checkpoint(ctx, &report)
+	case MaintenanceQuickCheck, MaintenanceIntegrityCheck:
+		report.Problems, err = c.queryStrings(ctx, "PRAGMA "+string(task))
+		if len(report.Problems) == 1 && report.Problems[0] == "ok" {
+			report.Problems = nil
+		}
+	default:
+		return report, fmt.Errorf("unknown maintenance task %q", task)
+	}
+	if err != nil {
+		return report, fmt.Errorf("%s: %w", task, err)
+	}
+
+	after, err := c.databaseSize(ctx)
+	if err != nil {
+		return report, err
+	}
+	report.After = after
+	report.Duration = time.Since(report.Started)
+
+	return report, nil
+}
+
+func (c *Client) runRetention(ctx context.Context) (MaintenanceReport, error) {
+	report := MaintenanceReport{Task: MaintenanceRetention, Started: time.Now()}
+
+	before, err := c.databaseSize(ctx)
+	if err != nil {
+		return report, err
+	}
+	report.Before = before
+
+	report.Purged, err = c.PurgeExpired(ctx)
+	if err != nil {
+		return report, fmt.Errorf("%s: %w", MaintenanceRetention, err)
+	}
+
+	after, err := c.databaseSize(ctx)
+	if err != nil {
+		return report, err
+	}
+	report.After = after
+	report.Duration = time.Since(report.Started)
+
+	return report, nil
+}
+
+func (c *Client) incrementalVacuum(ctx context.Context, pages int, report *MaintenanceReport) error {
+	modes, err := c.queryStrings(ctx, "PRAGMA auto_vacuum")
+	if err != nil {
+		return err
+	}
+	// 2 is INCREMENTAL, the mode can only be switched before the first table is created or with a full VACUUM
+	if len(modes) == 0 || modes[0] != "2" {
+		report.Skipped = true
+		report.Reason = "auto_vacuum is not INCREMENTAL"
+		return nil
+	}
+	if report.Before.FreePages == 0 {
+		report.Skipped = true
+		report.Reason = "no free pages"
+		return nil
+	}
+
+	return c.drain(ctx, fmt.Sprintf("PRAGMA incremental_vacuum(%d)", pages))
+}
+
+func (c *Client) checkpoint(ctx context.Context, report *MaintenanceReport) error {
+	modes, err := c.queryStrings(ctx, "PRAGMA journal_mode")
+	if err != nil {
+		return err
+	}
+	if len(modes) == 0 || !strings.EqualFold(modes[0], "wal") {
+		report.Skipped = true
+		report.Reason = "journal mode is not WAL"
+		return nil
+	}
+
+	var busy, frames, checkpointed int64
+	if err := c.db.QueryRowContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE)").Scan(&busy, &frames, &checkpointed); err != nil {
+		return err
+	}
+	if busy != 0 {
+		report.Skipped = true
+		report.Reason = fmt.Sprintf("blocked by readers, %d of %d frames checkpointed", checkpointed, frames)
+	}
+	return nil
+}
+
+func (c *Client) drain(ctx context.Context, query string) error {
+	rows, err := c.db.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+	}
+	return rows.Err()
+}
+
+func (c *Client) databaseSize(ctx context.Context) (DatabaseSize, error) {
+	var size DatabaseSize
+	for pragma, target := range map[string]*int64{
+		"page_count":     &size.Pages,
+		"page_size":      &size.PageSize,
+		"freelist_count": &size.FreePages,
+	} {
+		if err := c.db.QueryRowContext(ctx, "PRAGMA "+pragma).Scan(target); err != nil {
+			return size, fmt.Errorf("database size: %w", err)
+		}
+	}
+	size.Bytes = size.Pages * size.PageSize
+
+	var seq int64
+	var name, file string
+	if err := c.db.QueryRowContext(ctx, "PRAGMA database_list").Scan(&seq, &name, &file); err != nil {
+		return size, fmt.Errorf("database size: %w", err)
+	}
+	if file != "" {
+		if info, err := os.Stat(file + "-wal"); err == nil {
+			size.WALBytes = info.Size()
+		}
+	}
+
+	return size, nil
+}
+
+func (r MaintenanceReport) log() {
+	if r.Skipped {
+		log.Infof("SQLite maintenance %s skipped: %s", r.Task, r.Reason)
+		return
+	}
+	if len(r.Problems) > 0 {
+		log.Errorf("SQLite maintenance %s found %d problems: %s", r.Task, len(r.Problems), strings.Join(r.Problems, "; "))
+		return
+	}
+	if r.Task == MaintenanceRetention {
+		var purged int64
+		for _, n := range r.Purged {
+			purged += n
+		}
+		log.Infof("SQLite maintenance %s purged %d rows from %d tables", r.Task, purged, len(r.Purged))
+	}
+	log.Infof(
+		"SQLite maintenance %s done in %s: size %d -> %d bytes, free pages %d -> %d, wal %d -> %d bytes",
+		r.Task, r.Duration.Round(time.Millisecond),
+		r.Before.Bytes, r.After.Bytes,
+		r.Before.FreePages, r.After.FreePages,
+		r.Before.WALBytes, r.After.WALBytes,
+	)
+}
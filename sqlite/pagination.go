@@ -0,0 +1,204 @@
+package sqlite
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	cursorNext = "n"
+	cursorPrev = "p"
+)
+
+type OrderKey struct {
+	Column string
+	Desc   bool
+}
+
+type Page struct {
+	Rows []map[string]any
+	Next string
+	Prev string
+}
+
+type pageCursor struct {
+	Direction string `json:"d"`
+	Values    []any  `json:"v"`
+}
+
+func (c *Client) SelectPage(query string, orderBy []OrderKey, cursor string, limit int, args ...any) (Page, error) {
+	if c == nil || c.db == nil {
+		return Page{}, errors.New("db client is nil")
+	}
+	if len(orderBy) == 0 {
+		return Page{}, errors.New("order keys are empty")
+	}
+	if limit <= 0 {
+		return Page{}, fmt.Errorf("invalid page limit: %d", limit)
+	}
+	for _, key := range orderBy {
+		if key.Column == "" {
+			return Page{}, errors.New("order key column is empty")
+		}
+	}
+
+	position := pageCursor{Direction: cursorNext}
+	if cursor != "" {
+		decoded, err := decodePageCursor(cursor, len(orderBy))
+		if err != nil {
+			return Page{}, err
+		}
+		position = decoded
+	}
+	backward := position.Direction == cursorPrev
+
+	pageQuery, pageArgs := buildPageQuery(query, orderBy, position, limit)
+	rows, err := c.ExecSelect(pageQuery, append(append([]any{}, args...), pageArgs...)...)
+	if err != nil {
+		return Page{}, fmt.Errorf("select page: %w", err)
+	}
+
+	hasMore := len(rows) > limit
+	if hasMore {
+		rows = rows[:limit]
+	}
+	if backward {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+
+	page := Page{Rows: rows}
+	if len(rows) == 0 {
+		return page, nil
+	}
+
+	if backward || hasMore {
+		if page.Next, err = encodePageCursor(cursorNext, rows[len(rows)-1], orderBy); err != nil {
+			return Page{}, err
+		}
+	}
+	if (backward && hasMore) || (!backward && cursor != "") {
+		if page.Prev, err = encodePageCursor(cursorPrev, rows[0], orderBy); err != nil {
+			return Page{}, err
+		}
+	}
+
+	return page, nil
+}
+
+func (c *Client) SelectPageNamed(query string, orderBy []OrderKey, cursor string, limit int, params map[string]any) (Page, error) {
+	compiledQuery, args, err := buildMacrosQuery(query, params)
+	if err != nil {
+		return Page{}, fmt.Errorf("compile named query: %w", err)
+	}
+	return c.SelectPage(compiledQuery, orderBy, cursor, limit, args...)
+}
+
+func buildPageQuery(query string, orderBy []OrderKey, position pageCursor, limit int) (string, []any) {
+	backward := position.Direction == cursorPrev
+	inner := strings.TrimRight(strings.TrimSpace(query), "; \n\t")
+
+	var args []any
+	where := ""
+	if len(position.Values) > 0 {
+		alternatives := make([]string, 0, len(orderBy))
+		for i := range orderBy {
+			terms := make([]string, 0, i+1)
+			for j := 0; j < i; j++ {
+				terms = append(terms, fmt.Sprintf("%s = ?", escapeIdentifier(orderBy[j].Column)))
+				args = append(args, position.Values[j])
+			}
+
+			operator := ">"
+			if orderBy[i].Desc != backward {
+				operator = "<"
+			}
+			terms = append(terms, fmt.Sprintf("%s %s ?", escapeIdentifier(orderBy[i].Column), operator))
+			args = append(args, position.Values[i])
+
+			alternatives = append(alternatives, "("+strings.Join(terms, " AND ")+")")
+		}
+		where = "\nWHERE " + strings.Join(alternatives, "\n   OR ")
+	}
+
+	order := make([]string, 0, len(orderBy))
+	for _, key := range orderBy {
+		direction := "ASC"
+		if key.Desc != backward {
+			direction = "DESC"
+		}
+		order = append(order, escapeIdentifier(key.Column)+" "+direction)
+	}
+
+	return fmt.Sprintf(
+		"SELECT * FROM (\n%s\n) AS page%s\nORDER BY %s\nLIMIT %d",
+		inner,
+		where,
+		strings.Join(order, ", "),
+		limit+1,
+	), args
+}
+
+func encodePageCursor(direction string, row map[string]any, orderBy []OrderKey) (string, error) {
+	values := make([]any, 0, len(orderBy))
+	for _, key := range orderBy {
+		value, ok := row[key.Column]
+		if !ok {
+			return "", fmt.Errorf("order key %q is not in the result columns", key.Column)
+		}
+		if value == nil {
+			return "", fmt.Errorf("order key %q is NULL, keyset pagination needs non-null keys", key.Column)
+		}
+		if t, ok := value.(time.Time); ok {
+			value = formatTime(t)
+		}
+		values = append(values, value)
+	}
+
+	encoded, err := json.Marshal(pageCursor{Direction: direction, Values: values})
+	if err != nil {
+		return "", fmt.Errorf("encode cursor: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(encoded), nil
+}
+
+func decodePageCursor(cursor string, keys int) (pageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return pageCursor{}, fmt.Errorf("invalid cursor: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+
+	var position pageCursor
+	if err := decoder.Decode(&position); err != nil {
+		return pageCursor{}, fmt.Errorf("invalid cursor: %w", err)
+	}
+	if position.Direction != cursorNext && position.Direction != cursorPrev {
+		return pageCursor{}, fmt.Errorf("invalid cursor direction %q", position.Direction)
+	}
+	if len(position.Values) != keys {
+		return pageCursor{}, fmt.Errorf("cursor has %d keys, want %d", len(position.Values), keys)
+	}
+
+	for i, value := range position.Values {
+		number, ok := value.(json.Number)
+		if !ok {
+			continue
+		}
+		if n, err := number.Int64(); err == nil {
+			position.Values[i] = n
+		} else if f, err := number.Float64(); err == nil {
+			position.Values[i] = f
+		}
+	}
+
+	return position, nil
+}
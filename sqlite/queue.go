@@ -0,0 +1,472 @@
+package sqlite
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	engine "modernc.org/sqlite"
+)
+
+const (
+	queueTable = "job_queue"
+
+	JobPending = "pending"
+	JobRunning = "running"
+	JobDead    = "dead"
+
+	defaultJobMaxAttempts = 5
+	maxJobBackoff         = time.Hour
+
+	sqliteConstraintUnique = 2067
+)
+
+var ErrDuplicateJob = errors.New("job with this unique key is already queued")
+
+type QueueOptions struct {
+	MaxAttempts int
+	Backoff     func(attempt int) time.Duration
+}
+
+type JobOptions struct {
+	RunAt       time.Time
+	Priority    int
+	UniqueKey   string
+	MaxAttempts int
+}
+
+type Job struct {
+	ID          int64
+	Queue       string
+	Kind        string
+	Payload     []byte
+	UniqueKey   string
+	Priority    int
+	State       string
+	Attempts    int
+	MaxAttempts int
+	RunAt       time.Time
+	LeasedUntil time.Time
+	LastError   string
+	owner       string
+}
+
+type Queue struct {
+	client  *Client
+	name    string
+	options QueueOptions
+
+	// Workers of one queue share a client, their writes are serialized here instead of failing as busy
+	mutex sync.Mutex
+}
+
+func NewQueue(client *Client, name string) (*Queue, error) {
+	return NewQueueWithOptions(client, name, QueueOptions{})
+}
+
+func NewQueueWithOptions(client *Client, name string, opts QueueOptions) (*Queue, error) {
+	if client == nil || client.db == nil {
+		return nil, errors.New("db client is nil")
+	}
+	if name == "" {
+		return nil, errors.New("queue name is empty")
+	}
+	if opts.MaxAttempts < 0 {
+		return nil, fmt.Errorf("invalid max attempts: %d", opts.MaxAttempts)
+	}
+	if opts.MaxAttempts == 0 {
+		opts.MaxAttempts = defaultJobMaxAttempts
+	}
+	if opts.Backoff == nil {
+		opts.Backoff = exponentialBackoff
+	}
+
+	err := client.CreateTable(Table{
+		Name: queueTable,
+		Columns: []Column{
+			{Name: "id", Type: TypeInteger, PrimaryKey: boolPtr(true), AutoIncrement: boolPtr(true)},
+			{Name: "queue", Type: TypeText, NotNull: boolPtr(true)},
+			{Name: "kind", Type: TypeText, NotNull: boolPtr(true)},
+			{Name: "payload", Type: TypeBlob},
+			{Name: "unique_key", Type: TypeText},
+			{Name: "priority", Type: TypeInteger, NotNull: boolPtr(true), Default: stringPtr("0")},
+			{Name: "state", Type: TypeText, NotNull: boolPtr(true), Default: stringPtr("'" + JobPending + "'")},
+			{Name: "attempts", Type: TypeInteger, NotNull: boolPtr(true), Default: stringPtr("0")},
+			{Name: "max_attempts", Type: TypeInteger, NotNull: boolPtr(true)},
+			{Name: "run_at", Type: TypeText, NotNull: boolPtr(true)},
+			{Name: "leased_until", Type: TypeText},
+			{Name: "lease_owner", Type: TypeText},
+			{Name: "last_error", Type: TypeText},
+		},
+		Checks: []CheckConstraint{
+			{Name: queueTable + "_state", Expr: fmt.Sprintf("state IN ('%s', '%s', '%s')", JobPending, JobRunning, JobDead)},
+		},
+		Indexes: []Index{
+			{
+				Name: queueTable + "_ready",
+				Keys: []IndexKey{{Column: "queue"}, {Column: "state"}, {Column: "priority", Desc: true}, {Column: "run_at"}},
+			},
+			{
+				Name:    queueTable + "_unique_key",
+				Unique:  true,
+				Columns: []string{"queue", "unique_key"},
+				Where:   fmt.Sprintf("unique_key IS NOT NULL AND state <> '%s'", JobDead),
+			},
+		},
+		Timestamps: boolPtr(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create job queue: %w", err)
+	}
+
+	return &Queue{client: client, name: name, options: opts}, nil
+}
+
+func (q *Queue) Enqueue(kind string, payload any, runAt time.Time, priority int) (int64, error) {
+	return q.EnqueueWithOptions(kind, payload, JobOptions{RunAt: runAt, Priority: priority})
+}
+
+// A job whose unique key is still pending or running is not queued again, its id comes back with ErrDuplicateJob
+func (q *Queue) EnqueueWithOptions(kind string, payload any, opts JobOptions) (int64, error) {
+	if kind == "" {
+		return 0, errors.New("job kind is empty")
+	}
+	if opts.MaxAttempts < 0 {
+		return 0, fmt.Errorf("invalid max attempts: %d", opts.MaxAttempts)
+	}
+	if opts.MaxAttempts == 0 {
+		opts.MaxAttempts = q.options.MaxAttempts
+	}
+	if opts.RunAt.IsZero() {
+		opts.RunAt = time.Now()
+	}
+
+	encoded, err := encodeValue(payload)
+	if err != nil {
+		return 0, fmt.Errorf("enqueue %q: %w", kind, err)
+	}
+	var uniqueKey any
+	if opts.UniqueKey != "" {
+		uniqueKey = opts.UniqueKey
+	}
+
+	var id int64
+	err = q.transaction(func(tx *Tx) error {
+		if uniqueKey != nil {
+			var err error
+			if id, err = q.queued(tx, uniqueKey); err != nil {
+				return err
+			}
+			if id != 0 {
+				return ErrDuplicateJob
+			}
+		}
+
+		result, err := tx.exec(
+			"INSERT INTO "+queueTable+" (queue, kind, payload, unique_key, priority, max_attempts, run_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			q.name, kind, encoded, uniqueKey, opts.Priority, opts.MaxAttempts, queueTime(opts.RunAt),
+		)
+		// The only unique index is the one on the key, another connection queued it after the check
+		if isUniqueViolation(err) {
+			id, _ = q.queued(tx, uniqueKey)
+			return ErrDuplicateJob
+		}
+		if err != nil {
+			return err
+		}
+		id, err = result.LastInsertId()
+		return err
+	})
+	if errors.Is(err, ErrDuplicateJob) {
+		return id, err
+	}
+	if err != nil {
+		return 0, fmt.Errorf("enqueue %q: %w", kind, err)
+	}
+	return id, nil
+}
+
+// The id of the pending or running job with the unique key, 0 when there is none
+func (q *Queue) queued(tx *Tx, uniqueKey any) (int64, error) {
+	rows, err := tx.ExecSelect(
+		"SELECT id FROM "+queueTable+" WHERE queue = ? AND unique_key = ? AND state <> ?",
+		q.name, uniqueKey, JobDead,
+	)
+	if err != nil || len(rows) == 0 {
+		return 0, err
+	}
+	id, _ := rows[0]["id"].(int64)
+	return id, nil
+}
+
+// Takes the next due job of the given kinds, a job whose lease expired is taken again
+func (q *Queue) Lease(owner string, visibility time.Duration, kinds ...string) (*Job, error) {
+	if owner == "" {
+		return nil, errors.New("lease owner is empty")
+	}
+	if visibility <= 0 {
+		return nil, fmt.Errorf("invalid visibility timeout: %s", visibility)
+	}
+
+	now := queueTime(time.Now())
+	var job *Job
+	err := q.transaction(func(tx *Tx) error {
+		// A worker that died mid job still used up an attempt
+		err := tx.Execute(
+			"UPDATE "+queueTable+" SET state = ?, leased_until = NULL, lease_owner = NULL, last_error = 'lease expired'"+
+				" WHERE queue = ? AND state = ? AND leased_until <= ? AND attempts >= max_attempts",
+			JobDead, q.name, JobRunning, now,
+		)
+		if err != nil {
+			return err
+		}
+
+		query := "SELECT id FROM " + queueTable + " WHERE queue = ?" +
+			" AND ((state = ? AND run_at <= ?) OR (state = ? AND leased_until <= ?))"
+		args := []any{q.name, JobPending, now, JobRunning, now}
+		if len(kinds) > 0 {
+			query += " AND kind IN (" + placeholders(len(kinds)) + ")"
+			for _, kind := range kinds {
+				args = append(args, kind)
+			}
+		}
+		query += " ORDER BY priority DESC, run_at, id LIMIT 1"
+
+		rows, err := tx.ExecSelect(
+			"UPDATE "+queueTable+" SET state = ?, attempts = attempts + 1, leased_until = ?, lease_owner = ?"+
+				" WHERE id = ("+query+") RETURNING *",
+			append([]any{JobRunning, queueTime(time.Now().Add(visibility)), owner}, args...)...,
+		)
+		if err != nil || len(rows) == 0 {
+			return err
+		}
+
+		job, err = jobFromRow(rows[0])
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("lease job: %w", err)
+	}
+	if job == nil {
+		return nil, ErrNotFound
+	}
+	return job, nil
+}
+
+func (q *Queue) Extend(job *Job, visibility time.Duration) error {
+	if job == nil {
+		return errors.New("job is nil")
+	}
+	if visibility <= 0 {
+		return fmt.Errorf("invalid visibility timeout: %s", visibility)
+	}
+
+	until := time.Now().Add(visibility)
+	if err := q.leased(job, "UPDATE "+queueTable+" SET leased_until = ?", queueTime(until)); err != nil {
+		return fmt.Errorf("extend job %d: %w", job.ID, err)
+	}
+	job.LeasedUntil = until.UTC().Truncate(time.Millisecond)
+	return nil
+}
+
+func (q *Queue) Complete(job *Job) error {
+	if job == nil {
+		return errors.New("job is nil")
+	}
+	if err := q.leased(job, "DELETE FROM "+queueTable); err != nil {
+		return fmt.Errorf("complete job %d: %w", job.ID, err)
+	}
+	return nil
+}
+
+// Returns the job to pending without counting the attempt, for jobs interrupted by a worker shutdown
+func (q *Queue) Release(job *Job) error {
+	if job == nil {
+		return errors.New("job is nil")
+	}
+
+	err := q.leased(job,
+		"UPDATE "+queueTable+" SET state = ?, attempts = MAX(attempts - 1, 0), leased_until = NULL, lease_owner = NULL",
+		JobPending,
+	)
+	if err != nil {
+		return fmt.Errorf("release job %d: %w", job.ID, err)
+	}
+	job.State = JobPending
+	job.Attempts = max(job.Attempts-1, 0)
+	return nil
+}
+
+// Schedules a retry with backoff, or marks the job dead once it used all attempts
+func (q *Queue) Fail(job *Job, cause error) error {
+	if job == nil {
+		return errors.New("job is nil")
+	}
+
+	message := "failed"
+	if cause != nil {
+		message = cause.Error()
+	}
+
+	var err error
+	if job.Attempts >= job.MaxAttempts {
+		err = q.leased(job,
+			"UPDATE "+queueTable+" SET state = ?, leased_until = NULL, lease_owner = NULL, last_error = ?",
+			JobDead, message,
+		)
+		job.State = JobDead
+	} else {
+		runAt := time.Now().Add(q.options.Backoff(job.Attempts))
+		err = q.leased(job,
+			"UPDATE "+queueTable+" SET state = ?, run_at = ?, leased_until = NULL, lease_owner = NULL, last_error = ?",
+			JobPending, queueTime(runAt), message,
+		)
+		job.State = JobPending
+		job.RunAt = runAt.UTC().Truncate(time.Millisecond)
+	}
+	if err != nil {
+		return fmt.Errorf("fail job %d: %w", job.ID, err)
+	}
+	job.LastError = message
+	return nil
+}
+
+func (q *Queue) Dead() ([]Job, error) {
+	rows, err := q.client.ExecSelect("SELECT * FROM "+queueTable+" WHERE queue = ? AND state = ? ORDER BY id", q.name, JobDead)
+	if err != nil {
+		return nil, fmt.Errorf("dead jobs: %w", err)
+	}
+
+	jobs := make([]Job, 0, len(rows))
+	for _, row := range rows {
+		job, err := jobFromRow(row)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, *job)
+	}
+	return jobs, nil
+}
+
+func (q *Queue) Retry(id int64) error {
+	var affected int64
+	err := q.transaction(func(tx *Tx) error {
+		result, err := tx.exec(
+			"UPDATE "+queueTable+" SET state = ?, attempts = 0, run_at = ?, last_error = NULL WHERE queue = ? AND id = ? AND state = ?",
+			JobPending, queueTime(time.Now()), q.name, id, JobDead,
+		)
+		if err != nil {
+			return err
+		}
+		affected, err = result.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("retry job %d: %w", id, err)
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (j *Job) Decode(dest any) error {
+	if err := decodeValue(j.Payload, dest); err != nil {
+		return fmt.Errorf("decode job %d payload: %w", j.ID, err)
+	}
+	return nil
+}
+
+func (q *Queue) transaction(fn func(tx *Tx) error) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	return q.client.transactionWithTimeout(defaultTransactionTimeout, fn)
+}
+
+// Runs a statement against the job only while the caller still holds its lease
+func (q *Queue) leased(job *Job, statement string, args ...any) error {
+	return q.transaction(func(tx *Tx) error {
+		result, err := tx.exec(
+			statement+" WHERE id = ? AND state = ? AND lease_owner = ?",
+			append(args, job.ID, JobRunning, job.owner)...,
+		)
+		if err != nil {
+			return err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return ErrLeaseLost
+		}
+		return nil
+	})
+}
+
+func isUniqueViolation(err error) bool {
+	var sqliteErr *engine.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqliteConstraintUnique
+}
+
+func exponentialBackoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	if attempt > 12 {
+		return maxJobBackoff
+	}
+	return min(time.Second<<(attempt-1), maxJobBackoff)
+}
+
+func queueTime(t time.Time) string {
+	return t.UTC().Format(millisTimeLayout)
+}
+
+func jobFromRow(row map[string]any) (*Job, error) {
+	job := &Job{
+		Queue:     fmt.Sprint(row["queue"]),
+		Kind:      fmt.Sprint(row["kind"]),
+		State:     fmt.Sprint(row["state"]),
+		UniqueKey: textValue(row["unique_key"]),
+		LastError: textValue(row["last_error"]),
+		owner:     textValue(row["lease_owner"]),
+	}
+	job.ID, _ = row["id"].(int64)
+
+	for column, target := range map[string]*int{
+		"priority":     &job.Priority,
+		"attempts":     &job.Attempts,
+		"max_attempts": &job.MaxAttempts,
+	} {
+		n, _ := row[column].(int64)
+		*target = int(n)
+	}
+
+	switch v := row["payload"].(type) {
+	case []byte:
+		job.Payload = v
+	case string:
+		job.Payload = []byte(v)
+	}
+
+	for column, target := range map[string]*time.Time{
+		"run_at":       &job.RunAt,
+		"leased_until": &job.LeasedUntil,
+	} {
+		value := textValue(row[column])
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(millisTimeLayout, strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("job %d: invalid %s %q", job.ID, column, value)
+		}
+		*target = t
+	}
+
+	return job, nil
+}
@@ -0,0 +1,220 @@
+package sqlite_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/halushko/core-go/sqlite"
+	"github.com/halushko/core-go/sqlite/sqlitetest"
+)
+
+func newQueue(t *testing.T, client *sqlite.Client, opts sqlite.QueueOptions) *sqlite.Queue {
+	t.Helper()
+
+	queue, err := sqlite.NewQueueWithOptions(client, "test", opts)
+	if err != nil {
+		t.Fatalf("new queue: %v", err)
+	}
+	return queue
+}
+
+func TestEnqueueUniqueKeyViolation(t *testing.T) {
+	client := sqlitetest.New(t)
+	queue := newQueue(t, client, sqlite.QueueOptions{})
+
+	first, err := queue.EnqueueWithOptions("mail", "a", sqlite.JobOptions{UniqueKey: "user-1"})
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	// Hides the queued job from the first check, as if another connection queued it right after
+	hidden := false
+	err = client.Use(func(ctx context.Context, q *sqlite.Query, next sqlite.QueryHandler) error {
+		if !hidden && strings.HasPrefix(q.SQL, "SELECT id FROM job_queue") {
+			hidden = true
+			q.SQL = strings.Replace(q.SQL, "WHERE", "WHERE 0 AND", 1)
+		}
+		return next(ctx, q)
+	})
+	if err != nil {
+		t.Fatalf("use: %v", err)
+	}
+
+	id, err := queue.EnqueueWithOptions("mail", "b", sqlite.JobOptions{UniqueKey: "user-1"})
+	if !errors.Is(err, sqlite.ErrDuplicateJob) || id != first {
+		t.Fatalf("duplicate enqueue: id %d, err %v, want %d and ErrDuplicateJob", id, err, first)
+	}
+	sqlitetest.AssertRowCount(t, client, "job_queue", 1)
+}
+
+func TestQueueLease(t *testing.T) {
+	client := sqlitetest.New(t)
+	queue := newQueue(t, client, sqlite.QueueOptions{})
+
+	low, _ := queue.Enqueue("mail", "low", time.Now(), 0)
+	high, _ := queue.Enqueue("mail", "high", time.Now(), 10)
+	_, _ = queue.Enqueue("mail", "later", time.Now().Add(time.Hour), 20)
+	other, _ := queue.Enqueue("sms", "other", time.Now(), 30)
+
+	var leased []int64
+	for range 3 {
+		job, err := queue.Lease("worker", time.Minute, "mail")
+		if errors.Is(err, sqlite.ErrNotFound) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("lease: %v", err)
+		}
+		if job.State != sqlite.JobRunning || job.Attempts != 1 {
+			t.Errorf("job %d: state %s, attempts %d", job.ID, job.State, job.Attempts)
+		}
+		leased = append(leased, job.ID)
+	}
+	if want := []int64{high, low}; !reflect.DeepEqual(leased, want) {
+		t.Errorf("leased %v, want %v", leased, want)
+	}
+
+	job, err := queue.Lease("worker", time.Minute)
+	if err != nil || job.ID != other {
+		t.Fatalf("lease any kind: %+v, %v, want job %d", job, err, other)
+	}
+}
+
+func TestQueueRetryAndDeadLetter(t *testing.T) {
+	client := sqlitetest.New(t)
+	queue := newQueue(t, client, sqlite.QueueOptions{MaxAttempts: 2, Backoff: func(int) time.Duration { return 0 }})
+
+	id, _ := queue.Enqueue("mail", "a", time.Now(), 0)
+	for attempt := 1; attempt <= 2; attempt++ {
+		job, err := queue.Lease("worker", time.Minute)
+		if err != nil {
+			t.Fatalf("lease attempt %d: %v", attempt, err)
+		}
+		if job.Attempts != attempt {
+			t.Errorf("attempts %d, want %d", job.Attempts, attempt)
+		}
+		if err := queue.Fail(job, errors.New("smtp down")); err != nil {
+			t.Fatalf("fail attempt %d: %v", attempt, err)
+		}
+	}
+
+	if _, err := queue.Lease("worker", time.Minute); !errors.Is(err, sqlite.ErrNotFound) {
+		t.Fatalf("lease dead job: %v, want ErrNotFound", err)
+	}
+	dead, err := queue.Dead()
+	if err != nil || len(dead) != 1 || dead[0].ID != id || dead[0].LastError != "smtp down" {
+		t.Fatalf("dead: %+v, %v", dead, err)
+	}
+
+	if err := queue.Retry(id); err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	if err := queue.Retry(id); !errors.Is(err, sqlite.ErrNotFound) {
+		t.Fatalf("retry of a pending job: %v, want ErrNotFound", err)
+	}
+	job, err := queue.Lease("worker", time.Minute)
+	if err != nil || job.ID != id || job.Attempts != 1 {
+		t.Fatalf("lease after retry: %+v, %v", job, err)
+	}
+}
+
+func TestQueueRelease(t *testing.T) {
+	client := sqlitetest.New(t)
+	queue := newQueue(t, client, sqlite.QueueOptions{})
+
+	_, _ = queue.Enqueue("mail", "a", time.Now(), 0)
+	job, err := queue.Lease("worker", time.Minute)
+	if err != nil {
+		t.Fatalf("lease: %v", err)
+	}
+	if err := queue.Release(job); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if err := queue.Complete(job); !errors.Is(err, sqlite.ErrLeaseLost) {
+		t.Fatalf("complete after release: %v, want ErrLeaseLost", err)
+	}
+
+	job, err = queue.Lease("worker", time.Minute)
+	if err != nil || job.Attempts != 1 {
+		t.Fatalf("lease after release: %+v, %v", job, err)
+	}
+	if err := queue.Complete(job); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	sqlitetest.AssertRowCount(t, client, "job_queue", 0)
+}
+
+func TestQueueExpiredLease(t *testing.T) {
+	client := sqlitetest.New(t)
+	queue := newQueue(t, client, sqlite.QueueOptions{MaxAttempts: 2})
+
+	id, _ := queue.Enqueue("mail", "a", time.Now(), 0)
+	stale, err := queue.Lease("first", time.Millisecond)
+	if err != nil {
+		t.Fatalf("lease: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	job, err := queue.Lease("second", time.Millisecond)
+	if err != nil || job.ID != id || job.Attempts != 2 {
+		t.Fatalf("lease of an expired job: %+v, %v", job, err)
+	}
+	if err := queue.Extend(stale, time.Minute); !errors.Is(err, sqlite.ErrLeaseLost) {
+		t.Errorf("extend by the old owner: %v, want ErrLeaseLost", err)
+	}
+	if err := queue.Complete(stale); !errors.Is(err, sqlite.ErrLeaseLost) {
+		t.Errorf("complete by the old owner: %v, want ErrLeaseLost", err)
+	}
+
+	// The second lease expires too and used the last attempt
+	time.Sleep(5 * time.Millisecond)
+	if _, err := queue.Lease("third", time.Minute); !errors.Is(err, sqlite.ErrNotFound) {
+		t.Fatalf("lease after the last attempt: %v, want ErrNotFound", err)
+	}
+	dead, err := queue.Dead()
+	if err != nil || len(dead) != 1 || dead[0].LastError != "lease expired" {
+		t.Fatalf("dead: %+v, %v", dead, err)
+	}
+}
+
+func TestWorkerCancelsJobOnLostLease(t *testing.T) {
+	client := sqlitetest.New(t)
+	queue := newQueue(t, client, sqlite.QueueOptions{})
+	worker, err := sqlite.NewWorker(queue, sqlite.WorkerOptions{Visibility: 50 * time.Millisecond, PollInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("new worker: %v", err)
+	}
+
+	started, cancelled := make(chan struct{}), make(chan struct{})
+	worker.Handle("mail", func(ctx context.Context, job *sqlite.Job) error {
+		close(started)
+		<-ctx.Done()
+		close(cancelled)
+		return ctx.Err()
+	})
+	_, _ = queue.Enqueue("mail", "a", time.Now(), 0)
+
+	ctx, stop := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- worker.Run(ctx) }()
+	defer func() {
+		stop()
+		<-done
+	}()
+
+	<-started
+	sqlitetest.Exec(t, client, "UPDATE job_queue SET lease_owner = 'other', leased_until = '9999-12-31 00:00:00.000'")
+
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler was not cancelled after its lease was lost")
+	}
+	// The job is left to the new owner instead of being failed
+	sqlitetest.AssertRows(t, client, []map[string]any{{"state": sqlite.JobRunning, "attempts": int64(1), "lease_owner": "other"}},
+		"SELECT state, attempts, lease_owner FROM job_queue")
+}
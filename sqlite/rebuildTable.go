@@ -0,0 +1,440 @@
+package sqlite
+
+import (
+	"context"
+	external "database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var createObjectRegexp = regexp.MustCompile(`(?is)^\s*CREATE\s+(?:UNIQUE\s+)?(?:INDEX|TRIGGER)\s+(?:IF\s+NOT\s+EXISTS\s+)?`)
+
+var tableConstraintKeywords = map[string]bool{
+	"CONSTRAINT": true,
+	"PRIMARY":    true,
+	"UNIQUE":     true,
+	"CHECK":      true,
+	"FOREIGN":    true,
+}
+
+type sqlWord struct {
+	text   string
+	quoted bool
+}
+
+// The documented 12-step procedure: https://www.sqlite.org/lang_altertable.html#otheralter
+func (c *Client) rebuildTable(table string, alter func(definitions []string) ([]string, error)) (err error) {
+	if table == "" {
+		return errors.New("table name is empty")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultAlterTimeout)
+	defer cancel()
+
+	if c.mutex != nil {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+	}
+
+	// foreign_keys can only be switched outside a transaction, so the whole rebuild stays on one connection
+	db, err := c.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("rebuild connection: %w", err)
+	}
+	defer db.Close()
+
+	var foreignKeys int64
+	err = c.intercept(ctx, &Query{Kind: QuerySelect, SQL: "PRAGMA foreign_keys"}, func(ctx context.Context, q *Query) error {
+		q.Rows = 1
+		return db.QueryRowContext(ctx, q.SQL, q.Args...).Scan(&foreignKeys)
+	})
+	if err != nil {
+		return fmt.Errorf("read foreign_keys: %w", err)
+	}
+	if foreignKeys != 0 {
+		if err := c.execOnConn(ctx, db, "PRAGMA foreign_keys = OFF"); err != nil {
+			return fmt.Errorf("disable foreign keys: %w", err)
+		}
+		defer func() {
+			if restoreErr := c.execOnConn(context.Background(), db, "PRAGMA foreign_keys = ON"); restoreErr != nil {
+				err = errors.Join(err, fmt.Errorf("enable foreign keys: %w", restoreErr))
+			}
+		}()
+	}
+
+	return c.intercept(ctx, &Query{Kind: QueryTransaction}, func(ctx context.Context, q *Query) (err error) {
+		sqlTx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("begin transaction: %w", err)
+		}
+		defer func() {
+			if err != nil {
+				if rbErr := sqlTx.Rollback(); rbErr != nil && !errors.Is(rbErr, external.ErrTxDone) {
+					err = errors.Join(err, fmt.Errorf("rollback: %w", rbErr))
+				}
+			}
+		}()
+
+		if err = rebuildInTx(&Tx{tx: sqlTx, ctx: ctx, client: c}, table, alter); err != nil {
+			return err
+		}
+		if err = sqlTx.Commit(); err != nil {
+			return fmt.Errorf("commit: %w", err)
+		}
+		return nil
+	})
+}
+
+func (c *Client) execOnConn(ctx context.Context, db *external.Conn, query string) error {
+	return c.intercept(ctx, &Query{Kind: QueryExecute, SQL: query}, func(ctx context.Context, q *Query) error {
+		result, err := db.ExecContext(ctx, q.SQL, q.Args...)
+		if err != nil {
+			return err
+		}
+		q.Rows, _ = result.RowsAffected()
+		return nil
+	})
+}
+
+func rebuildInTx(tx *Tx, table string, alter func(definitions []string) ([]string, error)) error {
+	schema, name := tx.client.schemas().split(table)
+	prefix := ""
+	if schema != "" {
+		prefix = escapeIdentifier(schema) + "."
+	}
+
+	objects, err := tx.ExecSelect(
+		"SELECT type, name, sql FROM "+prefix+"sqlite_master WHERE tbl_name = ? AND sql IS NOT NULL ORDER BY rowid",
+		name,
+	)
+	if err != nil {
+		return fmt.Errorf("read schema of %q: %w", table, err)
+	}
+
+	var statement string
+	var dependents []string
+	for _, object := range objects {
+		switch object["type"] {
+		case "table":
+			statement = fmt.Sprint(object["sql"])
+		case "index", "trigger":
+			dependents = append(dependents, qualifyCreateStatement(fmt.Sprint(object["sql"]), prefix))
+		}
+	}
+	if statement == "" {
+		return fmt.Errorf("table %q not found", table)
+	}
+
+	_, body, tail, err := splitCreateTable(statement)
+	if err != nil {
+		return err
+	}
+	definitions, err := alter(splitDefinitions(body))
+	if err != nil {
+		return err
+	}
+
+	rebuilt := name + "_rebuild"
+	create := fmt.Sprintf(
+		"CREATE TABLE %s%s\n(\n    %s\n)%s",
+		prefix, escapeIdentifier(rebuilt), strings.Join(definitions, ",\n    "), tail,
+	)
+	if err := tx.Execute(create); err != nil {
+		return fmt.Errorf("create rebuilt table: %w", err)
+	}
+
+	oldColumns, err := storedColumns(tx, prefix, name)
+	if err != nil {
+		return err
+	}
+	newColumns, err := storedColumns(tx, prefix, rebuilt)
+	if err != nil {
+		return err
+	}
+	var copied []string
+	for _, column := range newColumns {
+		for _, old := range oldColumns {
+			if strings.EqualFold(column, old) {
+				copied = append(copied, column)
+				break
+			}
+		}
+	}
+	if len(copied) > 0 {
+		columns := joinEscapedIdentifiers(copied)
+		query := fmt.Sprintf(
+			"INSERT INTO %s%s (%s) SELECT %s FROM %s%s",
+			prefix, escapeIdentifier(rebuilt), columns, columns, prefix, escapeIdentifier(name),
+		)
+		if err := tx.Execute(query); err != nil {
+			return fmt.Errorf("copy rows: %w", err)
+		}
+	}
+
+	// AUTOINCREMENT must not hand out ids of rows that were deleted before the rebuild
+	sequence, err := tx.ExecSelect(
+		"SELECT 1 FROM " + prefix + "sqlite_master WHERE type = 'table' AND name = 'sqlite_sequence'",
+	)
+	if err != nil {
+		return fmt.Errorf("read sequence: %w", err)
+	}
+	var seq any
+	if len(sequence) > 0 {
+		rows, err := tx.ExecSelect("SELECT seq FROM "+prefix+"sqlite_sequence WHERE name = ?", name)
+		if err != nil {
+			return fmt.Errorf("read sequence: %w", err)
+		}
+		if len(rows) > 0 {
+			seq = rows[0]["seq"]
+		}
+	}
+
+	if err := tx.Execute("DROP TABLE " + prefix + escapeIdentifier(name)); err != nil {
+		return fmt.Errorf("drop old table: %w", err)
+	}
+
+	// Legacy renaming leaves views and triggers of other tables alone, they already use the final name
+	if err := tx.Execute("PRAGMA legacy_alter_table = ON"); err != nil {
+		return err
+	}
+	renameErr := tx.Execute(fmt.Sprintf("ALTER TABLE %s%s RENAME TO %s", prefix, escapeIdentifier(rebuilt), escapeIdentifier(name)))
+	if err := tx.Execute("PRAGMA legacy_alter_table = OFF"); err != nil {
+		return errors.Join(renameErr, err)
+	}
+	if renameErr != nil {
+		return fmt.Errorf("rename rebuilt table: %w", renameErr)
+	}
+
+	if seq != nil {
+		if err := tx.Execute("UPDATE "+prefix+"sqlite_sequence SET seq = max(seq, ?) WHERE name = ?", seq, name); err != nil {
+			return fmt.Errorf("restore sequence: %w", err)
+		}
+	}
+
+	for _, dependent := range dependents {
+		if err := tx.Execute(dependent); err != nil {
+			return fmt.Errorf("recreate %s: %w", strings.TrimSpace(dependent), err)
+		}
+	}
+
+	return checkRebuiltForeignKeys(tx, schema, name)
+}
+
+func checkRebuiltForeignKeys(tx *Tx, schema string, table string) error {
+	if schema == "" {
+		schema = "main"
+	}
+	prefix := escapeIdentifier(schema) + "."
+
+	tables := []string{table}
+	referencing, err := tx.ExecSelect(
+		"SELECT DISTINCT m.name FROM "+prefix+"sqlite_master AS m JOIN pragma_foreign_key_list(m.name, ?) AS f"+
+			" WHERE m.type = 'table' AND f.\"table\" = ? COLLATE NOCASE AND m.name <> ?",
+		schema, table, table,
+	)
+	if err != nil {
+		return fmt.Errorf("foreign key check: %w", err)
+	}
+	for _, row := range referencing {
+		tables = append(tables, fmt.Sprint(row["name"]))
+	}
+
+	var problems []string
+	for _, name := range tables {
+		rows, err := tx.ExecSelect(fmt.Sprintf("PRAGMA %sforeign_key_check(%s)", prefix, escapeIdentifier(name)))
+		if err != nil {
+			return fmt.Errorf("foreign key check: %w", err)
+		}
+		for _, row := range rows {
+			problems = append(problems, fmt.Sprintf("%v row %v references missing %v", row["table"], row["rowid"], row["parent"]))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("foreign key check failed: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Generated columns are computed by the new table and can not be copied
+func storedColumns(tx *Tx, prefix string, table string) ([]string, error) {
+	rows, err := tx.ExecSelect(fmt.Sprintf("PRAGMA %stable_xinfo(%s)", prefix, escapeIdentifier(table)))
+	if err != nil {
+		return nil, fmt.Errorf("describe %q: %w", table, err)
+	}
+
+	var columns []string
+	for _, row := range rows {
+		if hidden, ok := row["hidden"].(int64); ok && hidden != 0 {
+			continue
+		}
+		columns = append(columns, fmt.Sprint(row["name"]))
+	}
+	return columns, nil
+}
+
+// Stored statements of an attached database are unqualified and would land in main
+func qualifyCreateStatement(statement string, prefix string) string {
+	if prefix == "" {
+		return statement
+	}
+	loc := createObjectRegexp.FindStringIndex(statement)
+	if loc == nil {
+		return statement
+	}
+	return statement[:loc[1]] + prefix + statement[loc[1]:]
+}
+
+func splitCreateTable(statement string) (string, string, string, error) {
+	depth, start := 0, -1
+	for i := 0; i < len(statement); {
+		if next := skipSQLLiteral(statement, i); next > i {
+			i = next
+			continue
+		}
+		switch statement[i] {
+		case '(':
+			if depth == 0 {
+				start = i
+			}
+			depth++
+		case ')':
+			depth--
+			if depth == 0 && start >= 0 {
+				return statement[:start], statement[start+1 : i], statement[i+1:], nil
+			}
+		}
+		i++
+	}
+	return "", "", "", fmt.Errorf("can not parse table definition: %s", statement)
+}
+
+func splitDefinitions(body string) []string {
+	var definitions []string
+	depth, start := 0, 0
+	for i := 0; i < len(body); {
+		if next := skipSQLLiteral(body, i); next > i {
+			i = next
+			continue
+		}
+		switch body[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				definitions = append(definitions, strings.TrimSpace(body[start:i]))
+				start = i + 1
+			}
+		}
+		i++
+	}
+	if last := strings.TrimSpace(body[start:]); last != "" {
+		definitions = append(definitions, last)
+	}
+	return definitions
+}
+
+func definitionColumn(definition string) (string, bool) {
+	words := sqlWords(definition)
+	if len(words) == 0 {
+		return "", false
+	}
+	if !words[0].quoted && tableConstraintKeywords[strings.ToUpper(words[0].text)] {
+		return "", false
+	}
+	return words[0].text, true
+}
+
+func hasKeyword(words []sqlWord, keywords ...string) bool {
+	for _, word := range words {
+		if word.quoted {
+			continue
+		}
+		for _, keyword := range keywords {
+			if strings.EqualFold(word.text, keyword) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Identifiers and keywords outside string literals and comments, quoted identifiers are unquoted
+func sqlWords(s string) []sqlWord {
+	var words []sqlWord
+	for i := 0; i < len(s); {
+		switch ch := s[i]; {
+		case ch == '"' || ch == '`' || ch == '[':
+			end := skipSQLLiteral(s, i)
+			closing := ch
+			if ch == '[' {
+				closing = ']'
+			}
+			text := strings.TrimSuffix(s[i+1:end], string(closing))
+			if ch != '[' {
+				text = strings.ReplaceAll(text, string(ch)+string(ch), string(ch))
+			}
+			words = append(words, sqlWord{text: text, quoted: true})
+			i = end
+		case isWordStart(ch):
+			start := i
+			for i < len(s) && (isWordStart(s[i]) || s[i] >= '0' && s[i] <= '9' || s[i] == '$') {
+				i++
+			}
+			words = append(words, sqlWord{text: s[start:i]})
+		default:
+			if next := skipSQLLiteral(s, i); next > i {
+				i = next
+			} else {
+				i++
+			}
+		}
+	}
+	return words
+}
+
+func isWordStart(ch byte) bool {
+	return ch == '_' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= 0x80
+}
+
+// Returns the index after the quoted text or comment starting at i, or i when there is none
+func skipSQLLiteral(s string, i int) int {
+	switch s[i] {
+	case '\'', '"', '`':
+		quote := s[i]
+		for j := i + 1; j < len(s); j++ {
+			if s[j] != quote {
+				continue
+			}
+			if j+1 < len(s) && s[j+1] == quote {
+				j++
+				continue
+			}
+			return j + 1
+		}
+		return len(s)
+	case '[':
+		if j := strings.IndexByte(s[i:], ']'); j >= 0 {
+			return i + j + 1
+		}
+		return len(s)
+	case '-':
+		if strings.HasPrefix(s[i:], "--") {
+			if j := strings.IndexByte(s[i:], '\n'); j >= 0 {
+				return i + j + 1
+			}
+			return len(s)
+		}
+	case '/':
+		if strings.HasPrefix(s[i:], "/*") {
+			if j := strings.Index(s[i+2:], "*/"); j >= 0 {
+				return i + j + 4
+			}
+			return len(s)
+		}
+	}
+	return i
+}
@@ -0,0 +1,272 @@
+package sqlite
+
+import (
+	"encoding/csv"
+	"fmt"
+	"html"
+	"strings"
+	"unicode/utf8"
+)
+
+const ellipsis = "…"
+
+type RenderOptions struct {
+	// Subset and order of the columns, empty keeps all of them
+	Columns []string
+	// Longer cells are cut with an ellipsis, 0 keeps them whole. CSV cells are never cut
+	MaxCellWidth int
+	// Text and HTML lines are kept within this many characters by narrowing the widest columns
+	MaxWidth int
+	// Rows past the limit are summed up in a last line, 0 renders all of them.
+	// CSV is data rather than a view of it, so it always holds every row
+	MaxRows int
+	// Shown for NULL values, "NULL" by default and empty in CSV
+	Null string
+}
+
+var flattenReplacer = strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ", "\t", " ")
+
+// Widths are counted in runes, wide characters such as emoji take one column
+func (r Result) Render(format DataFormat, opts RenderOptions) (string, error) {
+	table, err := r.selectColumns(opts.Columns)
+	if err != nil {
+		return "", err
+	}
+
+	hidden := 0
+	if format != FormatCSV && opts.MaxRows > 0 && len(table.Rows) > opts.MaxRows {
+		hidden = len(table.Rows) - opts.MaxRows
+		table.Rows = table.Rows[:opts.MaxRows]
+	}
+
+	switch format {
+	case FormatText:
+		return renderText(table, opts, hidden), nil
+	case FormatHTML:
+		return "<pre>" + html.EscapeString(renderText(table, opts, hidden)) + "</pre>", nil
+	case FormatMarkdown:
+		return renderMarkdown(table, opts, hidden), nil
+	case FormatCSV:
+		return renderCSV(table, opts)
+	default:
+		return "", fmt.Errorf("unsupported render format: %s", format)
+	}
+}
+
+func (r Result) selectColumns(columns []string) (Result, error) {
+	if len(columns) == 0 {
+		return r, nil
+	}
+
+	indexes := make([]int, len(columns))
+	for i, column := range columns {
+		if indexes[i] = r.ColumnIndex(column); indexes[i] < 0 {
+			return Result{}, fmt.Errorf("result has no column %q", column)
+		}
+	}
+
+	selected := Result{Columns: columns, Rows: make([][]any, len(r.Rows))}
+	for i, values := range r.Rows {
+		row := make([]any, len(indexes))
+		for j, index := range indexes {
+			row[j] = values[index]
+		}
+		selected.Rows[i] = row
+	}
+	return selected, nil
+}
+
+func renderText(r Result, opts RenderOptions, hidden int) string {
+	header, cells := flatCells(r, opts)
+	if len(header) == 0 {
+		return moreRows(hidden)
+	}
+
+	widths := make([]int, len(header))
+	for i, name := range header {
+		widths[i] = utf8.RuneCountInString(name)
+	}
+	for _, row := range cells {
+		for i, cell := range row {
+			widths[i] = max(widths[i], utf8.RuneCountInString(cell))
+		}
+	}
+
+	if opts.MaxWidth > 0 {
+		total := 3 * (len(widths) - 1)
+		for _, width := range widths {
+			total += width
+		}
+		for total > opts.MaxWidth {
+			widest := 0
+			for i, width := range widths {
+				if width > widths[widest] {
+					widest = i
+				}
+			}
+			if widths[widest] <= 1 {
+				break
+			}
+			widths[widest]--
+			total--
+		}
+	}
+
+	numeric := numericColumns(r)
+	line := func(values []string) string {
+		parts := make([]string, len(values))
+		for i, value := range values {
+			value = truncate(value, widths[i])
+			padding := strings.Repeat(" ", widths[i]-utf8.RuneCountInString(value))
+			if numeric[i] {
+				parts[i] = padding + value
+			} else {
+				parts[i] = value + padding
+			}
+		}
+		return strings.TrimRight(strings.Join(parts, " | "), " ")
+	}
+
+	separator := make([]string, len(widths))
+	for i, width := range widths {
+		separator[i] = strings.Repeat("-", width)
+	}
+
+	lines := []string{line(header), strings.Join(separator, "-+-")}
+	for _, row := range cells {
+		lines = append(lines, line(row))
+	}
+	if hidden > 0 {
+		lines = append(lines, moreRows(hidden))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func renderMarkdown(r Result, opts RenderOptions, hidden int) string {
+	header, cells := flatCells(r, opts)
+	if len(header) == 0 {
+		return moreRows(hidden)
+	}
+
+	escape := strings.NewReplacer(`\`, `\\`, "|", `\|`)
+	line := func(values []string) string {
+		parts := make([]string, len(values))
+		for i, value := range values {
+			parts[i] = escape.Replace(value)
+		}
+		return "| " + strings.Join(parts, " | ") + " |"
+	}
+
+	numeric := numericColumns(r)
+	alignment := make([]string, len(header))
+	for i := range header {
+		alignment[i] = "---"
+		if numeric[i] {
+			alignment[i] = "---:"
+		}
+	}
+
+	lines := []string{line(header), "|" + strings.Join(alignment, "|") + "|"}
+	for _, row := range cells {
+		lines = append(lines, line(row))
+	}
+	if hidden > 0 {
+		lines = append(lines, "", moreRows(hidden))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Cells keep their line breaks and full length, the CSV quoting takes care of them
+func renderCSV(r Result, opts RenderOptions) (string, error) {
+	var out strings.Builder
+	encoder := &csvEncoder{writer: csv.NewWriter(&out)}
+	if err := encoder.header(r.Columns); err != nil {
+		return "", err
+	}
+
+	values := make([]any, len(r.Columns))
+	for _, row := range r.Rows {
+		for i, value := range row {
+			values[i] = cellText(value, opts.Null)
+		}
+		if err := encoder.row(values); err != nil {
+			return "", err
+		}
+	}
+
+	if err := encoder.flush(); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+func flatCells(r Result, opts RenderOptions) ([]string, [][]string) {
+	null := opts.Null
+	if null == "" {
+		null = "NULL"
+	}
+	flat := func(text string) string {
+		return truncate(flattenReplacer.Replace(text), opts.MaxCellWidth)
+	}
+
+	header := make([]string, len(r.Columns))
+	for i, column := range r.Columns {
+		header[i] = flat(column)
+	}
+
+	cells := make([][]string, len(r.Rows))
+	for i, values := range r.Rows {
+		row := make([]string, len(values))
+		for j, value := range values {
+			row[j] = flat(cellText(value, null))
+		}
+		cells[i] = row
+	}
+	return header, cells
+}
+
+// Columns holding only numbers and NULLs are aligned to the right
+func numericColumns(r Result) []bool {
+	numeric := make([]bool, len(r.Columns))
+	for i := range r.Columns {
+		found := false
+		numeric[i] = true
+		for _, values := range r.Rows {
+			switch values[i].(type) {
+			case nil:
+			case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+				found = true
+			default:
+				numeric[i] = false
+			}
+		}
+		numeric[i] = numeric[i] && found
+	}
+	return numeric
+}
+
+func cellText(value any, null string) string {
+	if value == nil {
+		return null
+	}
+	return textValue(value)
+}
+
+func truncate(text string, width int) string {
+	if width <= 0 || utf8.RuneCountInString(text) <= width {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:width-1]) + ellipsis
+}
+
+func moreRows(hidden int) string {
+	switch hidden {
+	case 0:
+		return ""
+	case 1:
+		return ellipsis + " 1 more row"
+	default:
+		return fmt.Sprintf("%s %d more rows", ellipsis, hidden)
+	}
+}
@@ -0,0 +1,31 @@
+package sqlite_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/halushko/core-go/sqlite"
+)
+
+func TestRenderCSVKeepsWholeCells(t *testing.T) {
+	result := sqlite.Result{
+		Columns: []string{"id", "body"},
+		Rows:    [][]any{{int64(1), "a long body"}, {int64(2), "short"}},
+	}
+
+	out, err := result.Render(sqlite.FormatCSV, sqlite.RenderOptions{MaxCellWidth: 4, MaxRows: 1})
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if expected := "id,body\n1,a long body\n2,short\n"; out != expected {
+		t.Fatalf("expected %q, got %q", expected, out)
+	}
+
+	out, err = result.Render(sqlite.FormatText, sqlite.RenderOptions{MaxCellWidth: 4})
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if strings.Contains(out, "a long body") {
+		t.Fatalf("expected the text cell to be cut, got %q", out)
+	}
+}
@@ -0,0 +1,643 @@
+package sqlite
+
+import (
+	"context"
+	external "database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var (
+	ErrNotFound = errors.New("record not found")
+	ErrConflict = errors.New("record version conflict")
+)
+
+var (
+	scannerType = reflect.TypeOf((*external.Scanner)(nil)).Elem()
+	valuerType  = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+	timeType    = reflect.TypeOf(time.Time{})
+)
+
+type Filter struct {
+	Where   string
+	Args    []any
+	OrderBy string
+	Limit   int
+	Offset  int
+}
+
+type repositoryField struct {
+	column  string
+	index   []int
+	pk      bool
+	version bool
+}
+
+type Repository[T any] struct {
+	client  *Client
+	table   string
+	fields  []repositoryField
+	keys    []repositoryField
+	version *repositoryField
+}
+
+func NewRepository[T any](client *Client, table string) (*Repository[T], error) {
+	if client == nil || client.db == nil {
+		return nil, errors.New("db client is nil")
+	}
+	if table == "" {
+		return nil, errors.New("table name is empty")
+	}
+
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("repository type %s is not a struct", typ)
+	}
+
+	repo := &Repository[T]{client: client, table: table}
+	for _, field := range reflect.VisibleFields(typ) {
+		if !field.IsExported() {
+			continue
+		}
+		tag, hasTag := field.Tag.Lookup("db")
+		if tag == "-" {
+			continue
+		}
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && !hasTag {
+			continue
+		}
+
+		name, options, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = snakeCase(field.Name)
+		}
+
+		f := repositoryField{column: name, index: field.Index}
+		for _, option := range strings.Split(options, ",") {
+			switch strings.TrimSpace(option) {
+			case "pk":
+				f.pk = true
+			case "version":
+				f.version = true
+			}
+		}
+
+		if f.version {
+			if repo.version != nil {
+				return nil, fmt.Errorf("repository type %s has more than one version column", typ)
+			}
+			switch field.Type.Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			default:
+				return nil, fmt.Errorf("version field %s must be a signed integer", field.Name)
+			}
+			version := f
+			repo.version = &version
+		}
+
+		repo.fields = append(repo.fields, f)
+		if f.pk {
+			repo.keys = append(repo.keys, f)
+		}
+	}
+
+	if len(repo.keys) == 0 {
+		return nil, fmt.Errorf("repository type %s has no primary key, tag a field with `db:\"name,pk\"`", typ)
+	}
+
+	return repo, nil
+}
+
+func (r *Repository[T]) Get(id ...any) (*T, error) {
+	where, args, err := r.keyCondition(id)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.client.ExecSelect(fmt.Sprintf("SELECT %s FROM %s WHERE %s", r.columnList(), r.client.schemas().escape(r.table), where), args...)
+	if err != nil {
+		return nil, fmt.Errorf("get %q: %w", r.table, err)
+	}
+	if len(rows) == 0 {
+		return nil, ErrNotFound
+	}
+
+	entity := new(T)
+	if err := r.fill(entity, rows[0]); err != nil {
+		return nil, err
+	}
+	return entity, nil
+}
+
+func (r *Repository[T]) List(filter Filter) ([]T, error) {
+	query, args := r.selectQuery(r.columnList(), filter, true)
+
+	rows, err := r.client.ExecSelect(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list %q: %w", r.table, err)
+	}
+
+	out := make([]T, len(rows))
+	for i, row := range rows {
+		if err := r.fill(&out[i], row); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func (r *Repository[T]) Count(filter Filter) (int64, error) {
+	query, args := r.selectQuery("COUNT(*) AS n", filter, false)
+
+	rows, err := r.client.ExecSelect(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("count %q: %w", r.table, err)
+	}
+	n, _ := rows[0]["n"].(int64)
+	return n, nil
+}
+
+func (r *Repository[T]) Exists(id ...any) (bool, error) {
+	where, args, err := r.keyCondition(id)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := r.client.ExecSelect(fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s WHERE %s) AS found", r.client.schemas().escape(r.table), where), args...)
+	if err != nil {
+		return false, fmt.Errorf("exists %q: %w", r.table, err)
+	}
+	found, _ := rows[0]["found"].(int64)
+	return found == 1, nil
+}
+
+func (r *Repository[T]) Insert(entity *T) error {
+	if entity == nil {
+		return errors.New("entity is nil")
+	}
+	value := reflect.ValueOf(entity).Elem()
+
+	autoKey := r.autoKey(value)
+	if r.version != nil && value.FieldByIndex(r.version.index).IsZero() {
+		setInt(value.FieldByIndex(r.version.index), 1)
+	}
+
+	var columns []string
+	var args []any
+	for _, f := range r.fields {
+		if autoKey != nil && f.column == autoKey.column {
+			continue
+		}
+		columns = append(columns, f.column)
+		args = append(args, fieldValue(value.FieldByIndex(f.index)))
+	}
+
+	query := fmt.Sprintf(
+		"INSERT INTO %s (%s) VALUES (%s)",
+		r.client.schemas().escape(r.table),
+		joinEscapedIdentifiers(columns),
+		placeholders(len(columns)),
+	)
+	result, err := r.exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("insert into %q: %w", r.table, err)
+	}
+
+	if autoKey != nil {
+		id, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("insert into %q: %w", r.table, err)
+		}
+		setInt(value.FieldByIndex(autoKey.index), id)
+	}
+	return nil
+}
+
+func (r *Repository[T]) Update(entity *T, fields ...string) error {
+	if entity == nil {
+		return errors.New("entity is nil")
+	}
+	value := reflect.ValueOf(entity).Elem()
+
+	selected := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		selected[field] = true
+	}
+
+	var sets []string
+	var args []any
+	for _, f := range r.fields {
+		if f.pk || f.version {
+			continue
+		}
+		if len(selected) > 0 && !selected[f.column] {
+			continue
+		}
+		delete(selected, f.column)
+		sets = append(sets, escapeIdentifier(f.column)+" = ?")
+		args = append(args, fieldValue(value.FieldByIndex(f.index)))
+	}
+	for unknown := range selected {
+		return fmt.Errorf("unknown or key column %q", unknown)
+	}
+	if len(sets) == 0 {
+		return nil
+	}
+
+	return r.update(value, sets, args)
+}
+
+func (r *Repository[T]) UpdateChanged(before T, after *T) error {
+	if after == nil {
+		return errors.New("entity is nil")
+	}
+	old := reflect.ValueOf(before)
+	value := reflect.ValueOf(after).Elem()
+
+	var sets []string
+	var args []any
+	for _, f := range r.fields {
+		if f.pk || f.version {
+			continue
+		}
+		current := value.FieldByIndex(f.index)
+		if reflect.DeepEqual(old.FieldByIndex(f.index).Interface(), current.Interface()) {
+			continue
+		}
+		sets = append(sets, escapeIdentifier(f.column)+" = ?")
+		args = append(args, fieldValue(current))
+	}
+	if len(sets) == 0 {
+		return nil
+	}
+
+	return r.update(value, sets, args)
+}
+
+func (r *Repository[T]) Upsert(entity *T) error {
+	if entity == nil {
+		return errors.New("entity is nil")
+	}
+	value := reflect.ValueOf(entity).Elem()
+
+	if r.version != nil && value.FieldByIndex(r.version.index).IsZero() {
+		setInt(value.FieldByIndex(r.version.index), 1)
+	}
+
+	columns := make([]string, 0, len(r.fields))
+	args := make([]any, 0, len(r.fields))
+	var sets []string
+	for _, f := range r.fields {
+		columns = append(columns, f.column)
+		args = append(args, fieldValue(value.FieldByIndex(f.index)))
+		switch {
+		case f.pk:
+		case f.version:
+			sets = append(sets, fmt.Sprintf("%s = %s.%s + 1", escapeIdentifier(f.column), escapeIdentifier(r.baseTable()), escapeIdentifier(f.column)))
+		default:
+			sets = append(sets, fmt.Sprintf("%s = excluded.%s", escapeIdentifier(f.column), escapeIdentifier(f.column)))
+		}
+	}
+
+	keyColumns := make([]string, 0, len(r.keys))
+	for _, key := range r.keys {
+		keyColumns = append(keyColumns, key.column)
+	}
+
+	conflict := "DO NOTHING"
+	if len(sets) > 0 {
+		conflict = "DO UPDATE SET " + strings.Join(sets, ", ")
+	}
+
+	query := fmt.Sprintf(
+		"INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (%s) %s",
+		r.client.schemas().escape(r.table),
+		joinEscapedIdentifiers(columns),
+		placeholders(len(columns)),
+		joinEscapedIdentifiers(keyColumns),
+		conflict,
+	)
+
+	if r.version == nil {
+		if _, err := r.exec(query, args...); err != nil {
+			return fmt.Errorf("upsert into %q: %w", r.table, err)
+		}
+		return nil
+	}
+
+	rows, err := r.client.ExecSelect(query+" RETURNING "+escapeIdentifier(r.version.column)+" AS version", args...)
+	if err != nil {
+		return fmt.Errorf("upsert into %q: %w", r.table, err)
+	}
+	if len(rows) > 0 {
+		if version, ok := rows[0]["version"].(int64); ok {
+			setInt(value.FieldByIndex(r.version.index), version)
+		}
+	}
+	return nil
+}
+
+func (r *Repository[T]) Delete(id ...any) error {
+	where, args, err := r.keyCondition(id)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.exec(fmt.Sprintf("DELETE FROM %s WHERE %s", r.client.schemas().escape(r.table), where), args...)
+	if err != nil {
+		return fmt.Errorf("delete from %q: %w", r.table, err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("delete from %q: %w", r.table, err)
+	}
+	if affected == 0 {
+		// A soft delete trigger keeps the row and reports no change
+		exists, err := r.Exists(id...)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrNotFound
+		}
+	}
+	return nil
+}
+
+func (r *Repository[T]) update(value reflect.Value, sets []string, args []any) error {
+	where := make([]string, 0, len(r.keys)+1)
+	keyArgs := make([]any, 0, len(r.keys)+1)
+	for _, key := range r.keys {
+		where = append(where, escapeIdentifier(key.column)+" = ?")
+		keyArgs = append(keyArgs, fieldValue(value.FieldByIndex(key.index)))
+	}
+
+	var current int64
+	if r.version != nil {
+		current = value.FieldByIndex(r.version.index).Int()
+		sets = append(sets, fmt.Sprintf("%s = %s + 1", escapeIdentifier(r.version.column), escapeIdentifier(r.version.column)))
+		where = append(where, escapeIdentifier(r.version.column)+" = ?")
+		keyArgs = append(keyArgs, current)
+	}
+
+	query := fmt.Sprintf(
+		"UPDATE %s SET %s WHERE %s",
+		r.client.schemas().escape(r.table),
+		strings.Join(sets, ", "),
+		strings.Join(where, " AND "),
+	)
+	result, err := r.exec(query, append(args, keyArgs...)...)
+	if err != nil {
+		return fmt.Errorf("update %q: %w", r.table, err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("update %q: %w", r.table, err)
+	}
+	if affected == 0 {
+		exists, err := r.Exists(keyArgs[:len(r.keys)]...)
+		if err != nil {
+			return err
+		}
+		if exists && r.version != nil {
+			return ErrConflict
+		}
+		return ErrNotFound
+	}
+
+	if r.version != nil {
+		setInt(value.FieldByIndex(r.version.index), current+1)
+	}
+	return nil
+}
+
+func (r *Repository[T]) exec(query string, args ...any) (external.Result, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return r.client.executeContext(ctx, query, args...)
+}
+
+func (r *Repository[T]) keyCondition(id []any) (string, []any, error) {
+	if len(id) != len(r.keys) {
+		return "", nil, fmt.Errorf("table %q has %d key columns, got %d values", r.table, len(r.keys), len(id))
+	}
+
+	parts := make([]string, 0, len(r.keys))
+	for _, key := range r.keys {
+		parts = append(parts, escapeIdentifier(key.column)+" = ?")
+	}
+	return strings.Join(parts, " AND "), id, nil
+}
+
+func (r *Repository[T]) selectQuery(columns string, filter Filter, paging bool) (string, []any) {
+	query := fmt.Sprintf("SELECT %s FROM %s", columns, r.client.schemas().escape(r.table))
+	if strings.TrimSpace(filter.Where) != "" {
+		query += " WHERE " + filter.Where
+	}
+	if !paging {
+		return query, filter.Args
+	}
+
+	if filter.OrderBy != "" {
+		query += " ORDER BY " + filter.OrderBy
+	}
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", filter.Limit)
+		if filter.Offset > 0 {
+			query += fmt.Sprintf(" OFFSET %d", filter.Offset)
+		}
+	}
+	return query, filter.Args
+}
+
+func (r *Repository[T]) columnList() string {
+	columns := make([]string, 0, len(r.fields))
+	for _, f := range r.fields {
+		columns = append(columns, f.column)
+	}
+	return joinEscapedIdentifiers(columns)
+}
+
+func (r *Repository[T]) baseTable() string {
+	_, table := r.client.schemas().split(r.table)
+	return table
+}
+
+func (r *Repository[T]) autoKey(value reflect.Value) *repositoryField {
+	if len(r.keys) != 1 {
+		return nil
+	}
+	field := value.FieldByIndex(r.keys[0].index)
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if field.IsZero() {
+			return &r.keys[0]
+		}
+	}
+	return nil
+}
+
+func (r *Repository[T]) fill(entity *T, row map[string]any) error {
+	value := reflect.ValueOf(entity).Elem()
+	for _, f := range r.fields {
+		if err := assignValue(value.FieldByIndex(f.index), row[f.column]); err != nil {
+			return fmt.Errorf("column %q: %w", f.column, err)
+		}
+	}
+	return nil
+}
+
+func fieldValue(field reflect.Value) any {
+	if field.Type().Implements(valuerType) {
+		if field.Kind() == reflect.Pointer && field.IsNil() {
+			return nil
+		}
+		return field.Interface()
+	}
+	if field.Kind() == reflect.Pointer {
+		if field.IsNil() {
+			return nil
+		}
+		return fieldValue(field.Elem())
+	}
+	return field.Interface()
+}
+
+func assignValue(field reflect.Value, raw any) error {
+	if field.CanAddr() && field.Addr().Type().Implements(scannerType) {
+		return field.Addr().Interface().(external.Scanner).Scan(raw)
+	}
+
+	if field.Kind() == reflect.Pointer {
+		if raw == nil {
+			field.Set(reflect.Zero(field.Type()))
+			return nil
+		}
+		target := reflect.New(field.Type().Elem())
+		if err := assignValue(target.Elem(), raw); err != nil {
+			return err
+		}
+		field.Set(target)
+		return nil
+	}
+
+	if raw == nil {
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	}
+
+	if field.Type() == timeType {
+		switch v := raw.(type) {
+		case time.Time:
+			field.Set(reflect.ValueOf(v))
+			return nil
+		case string:
+			for _, layout := range importTimeLayouts {
+				if t, err := time.Parse(layout, v); err == nil {
+					field.Set(reflect.ValueOf(t))
+					return nil
+				}
+			}
+			return fmt.Errorf("cannot parse %q as time", v)
+		}
+	}
+
+	switch field.Kind() {
+	case reflect.Bool:
+		switch v := raw.(type) {
+		case int64:
+			field.SetBool(v != 0)
+			return nil
+		case bool:
+			field.SetBool(v)
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch v := raw.(type) {
+		case int64:
+			field.SetInt(v)
+			return nil
+		case float64:
+			field.SetInt(int64(v))
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if v, ok := raw.(int64); ok && v >= 0 {
+			field.SetUint(uint64(v))
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		switch v := raw.(type) {
+		case float64:
+			field.SetFloat(v)
+			return nil
+		case int64:
+			field.SetFloat(float64(v))
+			return nil
+		}
+	case reflect.String:
+		switch v := raw.(type) {
+		case string:
+			field.SetString(v)
+			return nil
+		case []byte:
+			field.SetString(string(v))
+			return nil
+		}
+	case reflect.Slice:
+		if field.Type().Elem().Kind() == reflect.Uint8 {
+			switch v := raw.(type) {
+			case []byte:
+				field.SetBytes(append([]byte(nil), v...))
+				return nil
+			case string:
+				field.SetBytes([]byte(v))
+				return nil
+			}
+		}
+	}
+
+	rv := reflect.ValueOf(raw)
+	if rv.Type().ConvertibleTo(field.Type()) {
+		field.Set(rv.Convert(field.Type()))
+		return nil
+	}
+	return fmt.Errorf("cannot assign %T to %s", raw, field.Type())
+}
+
+func setInt(field reflect.Value, v int64) {
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		field.SetInt(v)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		field.SetUint(uint64(v))
+	}
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+func snakeCase(name string) string {
+	var out strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+				out.WriteByte('_')
+			}
+			out.WriteRune(unicode.ToLower(r))
+			continue
+		}
+		out.WriteRune(r)
+	}
+	return out.String()
+}
@@ -0,0 +1,172 @@
+package sqlite
+
+import (
+	"context"
+	external "database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Rows of a select in the column order of the query
+type Result struct {
+	Columns []string
+	Rows    [][]any
+}
+
+// Without columns the keys of all rows are used in alphabetical order
+func NewResult(rows []map[string]any, columns ...string) Result {
+	if len(columns) == 0 {
+		seen := map[string]bool{}
+		for _, row := range rows {
+			for column := range row {
+				if !seen[column] {
+					seen[column] = true
+					columns = append(columns, column)
+				}
+			}
+		}
+		sort.Strings(columns)
+	}
+
+	result := Result{Columns: columns, Rows: make([][]any, len(rows))}
+	for i, row := range rows {
+		values := make([]any, len(columns))
+		for j, column := range columns {
+			values[j] = row[column]
+		}
+		result.Rows[i] = values
+	}
+	return result
+}
+
+func (r Result) Maps() []map[string]any {
+	out := make([]map[string]any, 0, len(r.Rows))
+	for _, values := range r.Rows {
+		m := make(map[string]any, len(r.Columns))
+		for i, column := range r.Columns {
+			m[column] = values[i]
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+func (r Result) ColumnIndex(name string) int {
+	for i, column := range r.Columns {
+		if column == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (c *Client) ExecSelectResult(query string, args ...any) (Result, error) {
+	return c.ExecSelectResultWithTimeout(query, 24*5*time.Hour, args...)
+}
+
+func (c *Client) ExecSelectResultNamed(query string, params map[string]any) (Result, error) {
+	compiledQuery, args, err := buildMacrosQuery(query, params)
+	if err != nil {
+		return Result{}, fmt.Errorf("compile named query: %w", err)
+	}
+	return c.ExecSelectResult(compiledQuery, args...)
+}
+
+func (c *Client) ExecSelectResultWithTimeout(query string, timeout time.Duration, args ...any) (Result, error) {
+	if c == nil || c.db == nil {
+		return Result{}, errors.New("db client is nil")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var out Result
+	err := c.intercept(ctx, &Query{Kind: QuerySelect, SQL: query, Args: args}, func(ctx context.Context, q *Query) error {
+		var err error
+		out, err = c.execSelectResult(ctx, q.SQL, q.Args...)
+		q.Rows = int64(len(out.Rows))
+		return err
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	return out, nil
+}
+
+// The query cache keeps maps, so ordered results always read the database
+func (c *Client) execSelectResult(ctx context.Context, query string, args ...any) (Result, error) {
+	if c.mutex != nil {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+	}
+
+	if threshold := c.scanThreshold.Load(); threshold > 0 {
+		c.warnFullScans(ctx, threshold, query, args...)
+	}
+
+	rows, err := c.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return Result{}, fmt.Errorf("select query: %w", err)
+	}
+	defer rows.Close()
+
+	return scanResult(rows)
+}
+
+func (tx *Tx) ExecSelectResult(query string, args ...any) (Result, error) {
+	if tx == nil || tx.tx == nil {
+		return Result{}, errors.New("transaction is nil")
+	}
+
+	var out Result
+	err := tx.intercept(&Query{Kind: QuerySelect, SQL: query, Args: args, InTransaction: true}, func(ctx context.Context, q *Query) error {
+		rows, err := tx.tx.QueryContext(ctx, q.SQL, q.Args...)
+		if err != nil {
+			return fmt.Errorf("select query: %w", err)
+		}
+		defer rows.Close()
+
+		out, err = scanResult(rows)
+		q.Rows = int64(len(out.Rows))
+		return err
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	return out, nil
+}
+
+func scanResult(rows *external.Rows) (Result, error) {
+	cols, err := rows.Columns()
+	if err != nil {
+		return Result{}, fmt.Errorf("columns: %w", err)
+	}
+
+	result := Result{Columns: cols, Rows: make([][]any, 0, 16)}
+
+	for rows.Next() {
+		raw := make([]any, len(cols))
+		ptrs := make([]any, len(cols))
+		for i := range raw {
+			ptrs[i] = &raw[i]
+		}
+
+		if err := rows.Scan(ptrs...); err != nil {
+			return Result{}, fmt.Errorf("scan: %w", err)
+		}
+
+		for i, v := range raw {
+			if b, ok := v.([]byte); ok {
+				raw[i] = string(b)
+			}
+		}
+		result.Rows = append(result.Rows, raw)
+	}
+
+	if err := rows.Err(); err != nil {
+		return Result{}, fmt.Errorf("rows err: %w", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,246 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultRetentionBatchSize = 500
+
+type retentionRule struct {
+	Retention
+	table      string
+	softDelete bool
+	// The column holds unix seconds rather than text timestamps
+	unixTime bool
+}
+
+func (c *Client) SetRetention(table string, rule Retention) error {
+	if c == nil || c.db == nil {
+		return errors.New("db client is nil")
+	}
+	if table == "" {
+		return errors.New("table name is empty")
+	}
+	if err := rule.validate(); err != nil {
+		return fmt.Errorf("retention of %q: %w", table, err)
+	}
+
+	columns, err := c.DescribeTable(table)
+	if err != nil {
+		return err
+	}
+	if len(columns) == 0 {
+		return fmt.Errorf("table %q not found", table)
+	}
+
+	registered := retentionRule{Retention: rule, table: table}
+	found := rule.Column == ""
+	for _, column := range columns {
+		if strings.EqualFold(column.Name, rule.Column) {
+			found = true
+			if rule.MaxAge > 0 {
+				switch columnAffinity(column.Type) {
+				case TypeInteger:
+					registered.unixTime = true
+				case TypeReal, TypeBlob:
+					return fmt.Errorf("retention of %q: column %q must hold text timestamps or unix seconds", table, rule.Column)
+				}
+			}
+		}
+		if strings.EqualFold(column.Name, ColumnDeletedAt) {
+			registered.softDelete = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("retention of %q references unknown column %q", table, rule.Column)
+	}
+	if registered.BatchSize == 0 {
+		registered.BatchSize = defaultRetentionBatchSize
+	}
+
+	c.retentionMutex.Lock()
+	defer c.retentionMutex.Unlock()
+
+	if c.retention == nil {
+		c.retention = map[string]retentionRule{}
+	}
+	c.retention[strings.ToLower(table)] = registered
+	return nil
+}
+
+func (c *Client) RemoveRetention(table string) {
+	if c == nil {
+		return
+	}
+
+	c.retentionMutex.Lock()
+	defer c.retentionMutex.Unlock()
+
+	delete(c.retention, strings.ToLower(table))
+}
+
+// Follows a renamed table or column, a rule whose column was dropped is removed
+func (c *Client) renameRetention(table string, newTable string, column string, newColumn string) {
+	c.retentionMutex.Lock()
+	defer c.retentionMutex.Unlock()
+
+	rule, ok := c.retention[strings.ToLower(table)]
+	if !ok {
+		return
+	}
+	delete(c.retention, strings.ToLower(table))
+
+	if column != "" {
+		if strings.EqualFold(column, ColumnDeletedAt) || strings.EqualFold(newColumn, ColumnDeletedAt) {
+			rule.softDelete = strings.EqualFold(newColumn, ColumnDeletedAt)
+		}
+		if strings.EqualFold(rule.Column, column) {
+			if newColumn == "" {
+				log.Warnf("SQLite retention of %q removed: column %q was dropped", table, column)
+				return
+			}
+			rule.Column = newColumn
+		}
+	}
+
+	rule.table = newTable
+	c.retention[strings.ToLower(newTable)] = rule
+}
+
+func (c *Client) PurgeExpired(ctx context.Context) (map[string]int64, error) {
+	if c == nil || c.db == nil {
+		return nil, errors.New("db client is nil")
+	}
+
+	c.retentionMutex.Lock()
+	rules := make([]retentionRule, 0, len(c.retention))
+	for _, rule := range c.retention {
+		rules = append(rules, rule)
+	}
+	c.retentionMutex.Unlock()
+
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].table < rules[j].table
+	})
+
+	purged := make(map[string]int64, len(rules))
+	var errs []error
+	for _, rule := range rules {
+		n, err := c.purgeTable(ctx, rule)
+		if n > 0 {
+			purged[rule.table] = n
+			log.Infof("SQLite retention purged %d rows from %q", n, rule.table)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("purge %q: %w", rule.table, err))
+		}
+	}
+
+	return purged, errors.Join(errs...)
+}
+
+func (c *Client) purgeTable(ctx context.Context, rule retentionRule) (int64, error) {
+	table := c.schemas().escape(rule.table)
+
+	type selection struct {
+		query string
+		args  []any
+	}
+	var selections []selection
+
+	if rule.MaxAge > 0 {
+		// Text and integers never compare by value, so the cutoff takes the form of the column
+		cutoff := "datetime('now', ?)"
+		if rule.unixTime {
+			cutoff = "unixepoch('now', ?)"
+		}
+		selections = append(selections, selection{
+			query: fmt.Sprintf(
+				"SELECT rowid AS retention_rowid FROM %s WHERE %s < %s LIMIT %d",
+				table, escapeIdentifier(rule.Column), cutoff, rule.BatchSize,
+			),
+			args: []any{fmt.Sprintf("-%d seconds", int64(rule.MaxAge/time.Second))},
+		})
+	}
+	if rule.MaxRows > 0 {
+		order := "rowid DESC"
+		if rule.Column != "" {
+			order = escapeIdentifier(rule.Column) + " DESC, rowid DESC"
+		}
+		selections = append(selections, selection{
+			query: fmt.Sprintf(
+				"SELECT rowid AS retention_rowid FROM %s ORDER BY %s LIMIT %d OFFSET %d",
+				table, order, rule.BatchSize, rule.MaxRows,
+			),
+		})
+	}
+
+	var total int64
+	for _, s := range selections {
+		for {
+			if err := ctx.Err(); err != nil {
+				return total, err
+			}
+
+			n, err := c.purgeBatch(ctx, rule, s.query, s.args...)
+			total += n
+			if err != nil {
+				return total, err
+			}
+			if n < int64(rule.BatchSize) {
+				break
+			}
+		}
+	}
+
+	return total, nil
+}
+
+// Each batch is its own short transaction so writers are never blocked for long
+func (c *Client) purgeBatch(ctx context.Context, rule retentionRule, query string, args ...any) (int64, error) {
+	var purged int64
+
+	err := c.transaction(ctx, func(tx *Tx) error {
+		rows, err := tx.ExecSelect(query, args...)
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+
+		ids := make([]any, len(rows))
+		for i, row := range rows {
+			ids[i] = row["retention_rowid"]
+		}
+		table := c.schemas().escape(rule.table)
+		in := placeholders(len(ids))
+
+		// The soft delete trigger only lets already deleted rows through
+		if rule.softDelete {
+			update := fmt.Sprintf(
+				"UPDATE %s SET %s = CURRENT_TIMESTAMP WHERE %s IS NULL AND rowid IN (%s)",
+				table, escapeIdentifier(ColumnDeletedAt), escapeIdentifier(ColumnDeletedAt), in,
+			)
+			if _, err := tx.exec(update, ids...); err != nil {
+				return err
+			}
+		}
+
+		result, err := tx.exec(fmt.Sprintf("DELETE FROM %s WHERE rowid IN (%s)", table, in), ids...)
+		if err != nil {
+			return err
+		}
+		purged, err = result.RowsAffected()
+		return err
+	})
+
+	return purged, err
+}
@@ -0,0 +1,65 @@
+package sqlite_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/halushko/core-go/sqlite"
+	"github.com/halushko/core-go/sqlite/sqlitetest"
+)
+
+func TestPurgeExpiredByColumnKind(t *testing.T) {
+	tests := []struct {
+		name   string
+		typ    sqlite.ColumnType
+		old    string
+		recent string
+	}{
+		{name: "text timestamps", typ: sqlite.TypeDatetime, old: "datetime('now', '-2 days')", recent: "datetime('now')"},
+		{name: "unix seconds", typ: sqlite.TypeInteger, old: "unixepoch('now', '-2 days')", recent: "unixepoch('now')"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := sqlitetest.New(t, sqlite.Table{
+				Name: "events",
+				Columns: []sqlite.Column{
+					{Name: "id", Type: sqlite.TypeInteger, PrimaryKey: boolPtr(true)},
+					{Name: "created_at", Type: tt.typ},
+				},
+			})
+			if err := client.Execute("INSERT INTO events (id, created_at) VALUES (1, " + tt.old + "), (2, " + tt.recent + ")"); err != nil {
+				t.Fatalf("insert: %v", err)
+			}
+			if err := client.SetRetention("events", sqlite.Retention{Column: "created_at", MaxAge: 24 * time.Hour}); err != nil {
+				t.Fatalf("set retention: %v", err)
+			}
+
+			purged, err := client.PurgeExpired(context.Background())
+			if err != nil {
+				t.Fatalf("purge: %v", err)
+			}
+			if purged["events"] != 1 {
+				t.Errorf("purged %d rows, want 1", purged["events"])
+			}
+			sqlitetest.AssertRows(t, client, []map[string]any{{"id": 2}}, "SELECT id FROM events")
+		})
+	}
+}
+
+func TestSetRetentionRejectsNonTimestampColumns(t *testing.T) {
+	client := sqlitetest.New(t, sqlite.Table{
+		Name: "readings",
+		Columns: []sqlite.Column{
+			{Name: "id", Type: sqlite.TypeInteger, PrimaryKey: boolPtr(true)},
+			{Name: "taken_at", Type: sqlite.TypeReal},
+		},
+	})
+
+	if err := client.SetRetention("readings", sqlite.Retention{Column: "taken_at", MaxAge: time.Hour}); err == nil {
+		t.Error("retention on a REAL column was accepted")
+	}
+	if err := client.SetRetention("readings", sqlite.Retention{Column: "taken_at", MaxRows: 10}); err != nil {
+		t.Errorf("row limit on a REAL column: %v", err)
+	}
+}
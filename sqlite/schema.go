@@ -0,0 +1,55 @@
+package sqlite
+
+import (
+	"fmt"
+	"strings"
+)
+
+func (c *Client) SchemaSQL() (string, error) {
+	rows, err := c.ExecSelect(`SELECT type, name, tbl_name, sql FROM sqlite_master
+		WHERE sql IS NOT NULL AND name NOT LIKE 'sqlite_%'
+		ORDER BY rowid`)
+	if err != nil {
+		return "", fmt.Errorf("read schema: %w", err)
+	}
+
+	var tables, views []string
+	byTable := map[string][]string{}
+	var orphans []string
+	isTable := map[string]bool{}
+
+	for _, row := range rows {
+		if row["type"] == "table" {
+			isTable[fmt.Sprint(row["name"])] = true
+		}
+	}
+
+	for _, row := range rows {
+		statement := strings.TrimSpace(fmt.Sprint(row["sql"])) + ";"
+		name := fmt.Sprint(row["name"])
+		owner := fmt.Sprint(row["tbl_name"])
+
+		switch row["type"] {
+		case "table":
+			tables = append(tables, name)
+			byTable[name] = append([]string{statement}, byTable[name]...)
+		case "view":
+			views = append(views, statement)
+		case "index", "trigger":
+			if isTable[owner] {
+				byTable[owner] = append(byTable[owner], statement)
+			} else {
+				orphans = append(orphans, statement)
+			}
+		}
+	}
+
+	var statements []string
+	for _, table := range tables {
+		statements = append(statements, byTable[table]...)
+	}
+	statements = append(statements, views...)
+	statements = append(statements, orphans...)
+
+	return joinStatements(statements), nil
+}
@@ -0,0 +1,267 @@
+package sqlite
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+type schemaFile struct {
+	Tables   []tableFile   `json:"tables" yaml:"tables"`
+	Views    []viewFile    `json:"views" yaml:"views"`
+	Triggers []triggerFile `json:"triggers" yaml:"triggers"`
+}
+
+type tableFile struct {
+	Name        string           `json:"name" yaml:"name"`
+	Columns     []columnFile     `json:"columns" yaml:"columns"`
+	PrimaryKey  *primaryKeyFile  `json:"primary_key" yaml:"primary_key"`
+	Unique      []uniqueFile     `json:"unique" yaml:"unique"`
+	Checks      []checkFile      `json:"checks" yaml:"checks"`
+	ForeignKeys []foreignKeyFile `json:"foreign_keys" yaml:"foreign_keys"`
+	Indexes     []indexFile      `json:"indexes" yaml:"indexes"`
+	Timestamps  *bool            `json:"timestamps" yaml:"timestamps"`
+	SoftDelete  *bool            `json:"soft_delete" yaml:"soft_delete"`
+	Retention   *retentionFile   `json:"retention" yaml:"retention"`
+	History     *historyFile     `json:"history" yaml:"history"`
+}
+
+type columnFile struct {
+	Name                 string             `json:"name" yaml:"name"`
+	Type                 string             `json:"type" yaml:"type"`
+	PrimaryKey           *bool              `json:"primary_key" yaml:"primary_key"`
+	PrimaryKeyOnConflict ConflictResolution `json:"primary_key_on_conflict" yaml:"primary_key_on_conflict"`
+	AutoIncrement        *bool              `json:"autoincrement" yaml:"autoincrement"`
+	NotNull              *bool              `json:"not_null" yaml:"not_null"`
+	NotNullOnConflict    ConflictResolution `json:"not_null_on_conflict" yaml:"not_null_on_conflict"`
+	Unique               *bool              `json:"unique" yaml:"unique"`
+	UniqueOnConflict     ConflictResolution `json:"unique_on_conflict" yaml:"unique_on_conflict"`
+	Generated            *string            `json:"generated" yaml:"generated"`
+	Stored               *bool              `json:"stored" yaml:"stored"`
+	// Any scalar, text literals keep their SQL quotes: "'active'"
+	Default any `json:"default" yaml:"default"`
+}
+
+type primaryKeyFile struct {
+	Name       string             `json:"name" yaml:"name"`
+	Columns    []string           `json:"columns" yaml:"columns"`
+	OnConflict ConflictResolution `json:"on_conflict" yaml:"on_conflict"`
+}
+
+type uniqueFile struct {
+	Name       string             `json:"name" yaml:"name"`
+	Columns    []string           `json:"columns" yaml:"columns"`
+	OnConflict ConflictResolution `json:"on_conflict" yaml:"on_conflict"`
+}
+
+type checkFile struct {
+	Name string `json:"name" yaml:"name"`
+	Expr string `json:"expr" yaml:"expr"`
+}
+
+type foreignKeyFile struct {
+	Name              string   `json:"name" yaml:"name"`
+	Columns           []string `json:"columns" yaml:"columns"`
+	ReferenceTable    string   `json:"reference_table" yaml:"reference_table"`
+	ReferenceColumns  []string `json:"reference_columns" yaml:"reference_columns"`
+	OnDelete          string   `json:"on_delete" yaml:"on_delete"`
+	OnUpdate          string   `json:"on_update" yaml:"on_update"`
+	Deferrable        *bool    `json:"deferrable" yaml:"deferrable"`
+	InitiallyDeferred *bool    `json:"initially_deferred" yaml:"initially_deferred"`
+}
+
+type indexFile struct {
+	Name    string         `json:"name" yaml:"name"`
+	Unique  bool           `json:"unique" yaml:"unique"`
+	Columns []string       `json:"columns" yaml:"columns"`
+	Keys    []indexKeyFile `json:"keys" yaml:"keys"`
+	Where   string         `json:"where" yaml:"where"`
+}
+
+type indexKeyFile struct {
+	Column  string `json:"column" yaml:"column"`
+	Expr    string `json:"expr" yaml:"expr"`
+	Collate string `json:"collate" yaml:"collate"`
+	Desc    bool   `json:"desc" yaml:"desc"`
+}
+
+type retentionFile struct {
+	Column    string       `json:"column" yaml:"column"`
+	MaxAge    fileDuration `json:"max_age" yaml:"max_age"`
+	MaxRows   int64        `json:"max_rows" yaml:"max_rows"`
+	BatchSize int          `json:"batch_size" yaml:"batch_size"`
+}
+
+type historyFile struct {
+	Retention fileDuration `json:"retention" yaml:"retention"`
+}
+
+type viewFile struct {
+	Name  string `json:"name" yaml:"name"`
+	Query string `json:"query" yaml:"query"`
+}
+
+type triggerFile struct {
+	Name    string   `json:"name" yaml:"name"`
+	Table   string   `json:"table" yaml:"table"`
+	Timing  string   `json:"timing" yaml:"timing"`
+	Event   string   `json:"event" yaml:"event"`
+	Columns []string `json:"columns" yaml:"columns"`
+	When    string   `json:"when" yaml:"when"`
+	Body    string   `json:"body" yaml:"body"`
+}
+
+// Written as Go durations such as "720h" or "90m"
+type fileDuration time.Duration
+
+func LoadSchema(fsys fs.FS, file string) (Schema, error) {
+	data, err := fs.ReadFile(fsys, file)
+	if err != nil {
+		return Schema{}, err
+	}
+
+	var document schemaFile
+	switch ext := strings.ToLower(path.Ext(file)); ext {
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		decoder.UseNumber()
+		err = decoder.Decode(&document)
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err = decoder.Decode(&document); errors.Is(err, io.EOF) {
+			err = nil
+		}
+	default:
+		return Schema{}, fmt.Errorf("unsupported schema format %q", ext)
+	}
+	if err != nil {
+		return Schema{}, fmt.Errorf("parse schema %q: %w", file, err)
+	}
+
+	schema := document.schema()
+	if err := schema.Validate(); err != nil {
+		return Schema{}, fmt.Errorf("invalid schema %q: %w", file, err)
+	}
+	return schema, nil
+}
+
+func (d *fileDuration) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return fmt.Errorf("duration must be a string such as \"720h\": %w", err)
+	}
+	return d.parse(text)
+}
+
+func (d *fileDuration) UnmarshalYAML(node *yaml.Node) error {
+	return d.parse(node.Value)
+}
+
+func (d *fileDuration) parse(text string) error {
+	duration, err := time.ParseDuration(strings.TrimSpace(text))
+	if err != nil {
+		return err
+	}
+	*d = fileDuration(duration)
+	return nil
+}
+
+// Numbers keep every digit and never get an exponent, so JSON and YAML give the same literal
+func defaultLiteral(value any) string {
+	switch v := value.(type) {
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return v.String()
+		}
+		if f, err := v.Float64(); err == nil {
+			return strconv.FormatFloat(f, 'f', -1, 64)
+		}
+		return v.String()
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func (f schemaFile) schema() Schema {
+	var schema Schema
+	for _, t := range f.Tables {
+		schema.Tables = append(schema.Tables, t.table())
+	}
+	for _, v := range f.Views {
+		schema.Views = append(schema.Views, View(v))
+	}
+	for _, tr := range f.Triggers {
+		schema.Triggers = append(schema.Triggers, Trigger(tr))
+	}
+	return schema
+}
+
+func (f tableFile) table() Table {
+	t := Table{Name: f.Name, Timestamps: f.Timestamps, SoftDelete: f.SoftDelete}
+
+	for _, c := range f.Columns {
+		column := Column{
+			Name:                 c.Name,
+			Type:                 ColumnType(strings.ToUpper(strings.TrimSpace(c.Type))),
+			PrimaryKey:           c.PrimaryKey,
+			PrimaryKeyOnConflict: c.PrimaryKeyOnConflict,
+			AutoIncrement:        c.AutoIncrement,
+			NotNull:              c.NotNull,
+			NotNullOnConflict:    c.NotNullOnConflict,
+			Unique:               c.Unique,
+			UniqueOnConflict:     c.UniqueOnConflict,
+			GeneratedExpr:        c.Generated,
+			Stored:               c.Stored,
+		}
+		if c.Default != nil {
+			column.Default = stringPtr(defaultLiteral(c.Default))
+		}
+		t.Columns = append(t.Columns, column)
+	}
+
+	if f.PrimaryKey != nil {
+		t.PrimaryKey = &PrimaryKey{Name: f.PrimaryKey.Name, Columns: f.PrimaryKey.Columns, OnConflict: f.PrimaryKey.OnConflict}
+	}
+	for _, u := range f.Unique {
+		t.UniqueConstraints = append(t.UniqueConstraints, UniqueConstraint(u))
+	}
+	for _, chk := range f.Checks {
+		t.Checks = append(t.Checks, CheckConstraint(chk))
+	}
+	for _, fk := range f.ForeignKeys {
+		t.ForeignKeys = append(t.ForeignKeys, ForeignKey(fk))
+	}
+	for _, idx := range f.Indexes {
+		index := Index{Name: idx.Name, Unique: idx.Unique, Columns: idx.Columns, Where: idx.Where}
+		for _, key := range idx.Keys {
+			index.Keys = append(index.Keys, IndexKey(key))
+		}
+		t.Indexes = append(t.Indexes, index)
+	}
+
+	if f.Retention != nil {
+		t.Retention = &Retention{
+			Column:    f.Retention.Column,
+			MaxAge:    time.Duration(f.Retention.MaxAge),
+			MaxRows:   f.Retention.MaxRows,
+			BatchSize: f.Retention.BatchSize,
+		}
+	}
+	if f.History != nil {
+		t.History = &History{Retention: time.Duration(f.History.Retention)}
+	}
+	return t
+}
@@ -0,0 +1,690 @@
+package sqlite
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type SchemaChangeKind string
+
+const (
+	ChangeCreateTable   SchemaChangeKind = "create table"
+	ChangeDropTable     SchemaChangeKind = "drop table"
+	ChangeAddColumn     SchemaChangeKind = "add column"
+	ChangeAlterColumn   SchemaChangeKind = "alter column"
+	ChangeDropColumn    SchemaChangeKind = "drop column"
+	ChangeAddForeignKey SchemaChangeKind = "add foreign key"
+	ChangeCreateIndex   SchemaChangeKind = "create index"
+	ChangeDropIndex     SchemaChangeKind = "drop index"
+	ChangeCreateView    SchemaChangeKind = "create view"
+	ChangeDropView      SchemaChangeKind = "drop view"
+	ChangeCreateTrigger SchemaChangeKind = "create trigger"
+	ChangeDropTrigger   SchemaChangeKind = "drop trigger"
+)
+
+type SchemaChange struct {
+	Kind   SchemaChangeKind
+	Object string
+	Detail string
+	apply  func(c *Client) error
+}
+
+// Without options the sync only adds and alters, nothing missing from the schema is dropped.
+// The tables of KV, Queue and leases, virtual tables with their shadow tables and the triggers
+// that keep spatial indexes in sync are never dropped, as the library owns them
+type SyncOptions struct {
+	DropTables  bool
+	DropColumns bool
+	// Indexes, views and triggers missing from the schema
+	DropObjects bool
+}
+
+type schemaObject struct {
+	kind  string
+	name  string
+	table string
+	sql   string
+}
+
+type schemaPlan struct {
+	client  *Client
+	names   schemaNames
+	opts    SyncOptions
+	objects map[string]map[string]schemaObject
+	changes []SchemaChange
+}
+
+var libraryTables = []string{kvTable, queueTable, leaseTable}
+
+var ifNotExistsRegexp = regexp.MustCompile(`(?i)^(CREATE\s+(?:UNIQUE\s+)?(?:TABLE|INDEX|VIEW|TRIGGER))\s+IF\s+NOT\s+EXISTS`)
+
+func (sc SchemaChange) String() string {
+	if sc.Detail == "" {
+		return fmt.Sprintf("%s %s", sc.Kind, sc.Object)
+	}
+	return fmt.Sprintf("%s %s: %s", sc.Kind, sc.Object, sc.Detail)
+}
+
+// Creates whatever is missing, existing objects are left as they are
+func (c *Client) ApplySchema(s Schema) error {
+	if c == nil || c.db == nil {
+		return errors.New("db client is nil")
+	}
+	if err := s.Validate(); err != nil {
+		return fmt.Errorf("invalid schema: %w", err)
+	}
+
+	for _, t := range s.Tables {
+		if err := c.CreateTable(t); err != nil {
+			return err
+		}
+	}
+	names := c.schemas()
+	for _, v := range s.Views {
+		if err := c.Execute(buildViewSQL(v, names, true)); err != nil {
+			return fmt.Errorf("create view %q: %w", v.Name, err)
+		}
+	}
+	for _, tr := range s.Triggers {
+		if err := c.Execute(buildTriggerSQL(tr, names, true)); err != nil {
+			return fmt.Errorf("create trigger %q: %w", tr.Name, err)
+		}
+	}
+	return nil
+}
+
+// Changes SyncSchema would make, in the order it would make them.
+// Unique and check constraints of existing tables and dropped foreign keys are not compared
+func (c *Client) PlanSchema(s Schema, opts SyncOptions) ([]SchemaChange, error) {
+	if c == nil || c.db == nil {
+		return nil, errors.New("db client is nil")
+	}
+	if err := s.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+
+	p := &schemaPlan{client: c, names: c.schemas(), opts: opts, objects: map[string]map[string]schemaObject{}}
+	if err := p.plan(s); err != nil {
+		return nil, fmt.Errorf("plan schema: %w", err)
+	}
+	return p.changes, nil
+}
+
+// Returns the applied changes, on error the ones applied before it
+func (c *Client) SyncSchema(s Schema, opts SyncOptions) ([]SchemaChange, error) {
+	changes, err := c.PlanSchema(s, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, change := range changes {
+		log.Infof("SQLite schema sync: %s", change)
+		if err := change.apply(c); err != nil {
+			return changes[:i], fmt.Errorf("sync schema: %s: %w", change, err)
+		}
+	}
+
+	// Rules live in memory, so they are registered on every sync
+	for _, t := range s.Tables {
+		if t.Retention != nil {
+			if err := c.SetRetention(t.Name, *t.Retention); err != nil {
+				return changes, err
+			}
+		}
+		if t.History != nil && t.History.Retention > 0 {
+			history := historyTable(t, c.schemas())
+			if err := c.SetRetention(history.Name, *history.Retention); err != nil {
+				return changes, err
+			}
+		}
+	}
+	return changes, nil
+}
+
+func (p *schemaPlan) plan(s Schema) error {
+	schemas := map[string]bool{"main": true}
+	tables := map[string]bool{}
+	views := map[string]bool{}
+	for _, t := range s.Tables {
+		schema, name := p.objectSchema(t.Name)
+		schemas[schema] = true
+		tables[schema+"."+strings.ToLower(name)] = true
+		if t.History != nil {
+			tables[schema+"."+strings.ToLower(name+HistoryTableSuffix)] = true
+		}
+	}
+	for _, v := range s.Views {
+		schema, name := p.objectSchema(v.Name)
+		schemas[schema] = true
+		views[schema+"."+strings.ToLower(name)] = true
+	}
+
+	// Views may read the columns that change, so they are dropped first and created last
+	var createViews []SchemaChange
+	for _, v := range s.Views {
+		existing, err := p.object(v.Name, "view")
+		if err != nil {
+			return err
+		}
+		if existing != nil && sameSQL(existing.sql, buildViewSQL(v, p.names, false)) {
+			continue
+		}
+		if existing != nil {
+			p.add(p.dropChange(ChangeDropView, "VIEW", v.Name, "definition changed"))
+		}
+		createViews = append(createViews, SchemaChange{
+			Kind:   ChangeCreateView,
+			Object: v.Name,
+			apply:  execChange(buildViewSQL(v, p.names, false)),
+		})
+	}
+
+	var viewTriggers []Trigger
+	tableTriggers := map[string][]Trigger{}
+	for _, tr := range s.Triggers {
+		owner := ""
+		for _, t := range s.Tables {
+			if _, table := p.names.split(t.Name); strings.EqualFold(table, tr.Table) {
+				owner = t.Name
+				break
+			}
+		}
+		if owner == "" {
+			viewTriggers = append(viewTriggers, tr)
+			continue
+		}
+		tableTriggers[owner] = append(tableTriggers[owner], tr)
+	}
+
+	for _, t := range s.Tables {
+		if err := p.planTable(t, tableTriggers[t.Name]); err != nil {
+			return err
+		}
+	}
+
+	p.changes = append(p.changes, createViews...)
+	createTriggers, err := p.planTriggers(viewTriggers, "", false)
+	if err != nil {
+		return err
+	}
+	p.changes = append(p.changes, createTriggers...)
+
+	names := make([]string, 0, len(schemas))
+	for schema := range schemas {
+		names = append(names, schema)
+	}
+	sort.Strings(names)
+
+	for _, schema := range names {
+		objects, err := p.schemaObjects(schema)
+		if err != nil {
+			return err
+		}
+		virtual := virtualTables(objects)
+		for _, object := range sortedObjects(objects) {
+			key := schema + "." + strings.ToLower(object.name)
+			name := qualifiedName(schema, object.name)
+			switch {
+			case object.kind == "view" && p.opts.DropObjects && !views[key]:
+				p.add(p.dropChange(ChangeDropView, "VIEW", name, "not in schema"))
+			case object.kind == "table" && p.opts.DropTables && !tables[key] && !isVirtualTable(object, virtual) && !isLibraryTable(object.name):
+				p.add(p.dropChange(ChangeDropTable, "TABLE", name, "not in schema"))
+			}
+		}
+	}
+	return nil
+}
+
+func (p *schemaPlan) planTable(t Table, triggers []Trigger) error {
+	existing, err := p.object(t.Name, "table")
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		p.add(SchemaChange{
+			Kind:   ChangeCreateTable,
+			Object: t.Name,
+			apply: func(c *Client) error {
+				return c.CreateTable(t)
+			},
+		})
+		creates, err := p.planTriggers(triggers, t.Name, false)
+		p.changes = append(p.changes, creates...)
+		return err
+	}
+
+	// Triggers may read the columns that change, so they are dropped first and created last
+	audited := withAuditColumns(t)
+	createTriggers, err := p.planTriggers(append(auditTriggers(audited, p.names), triggers...), t.Name, p.opts.DropObjects)
+	if err != nil {
+		return err
+	}
+
+	if err := p.planColumns(audited, p.opts.DropColumns); err != nil {
+		return err
+	}
+	if err := p.planForeignKeys(audited); err != nil {
+		return err
+	}
+	if err := p.planIndexes(audited); err != nil {
+		return err
+	}
+
+	if t.History != nil {
+		history := historyTable(t, p.names)
+		existingHistory, err := p.object(history.Name, "table")
+		if err != nil {
+			return err
+		}
+		if existingHistory == nil {
+			p.add(SchemaChange{
+				Kind:   ChangeCreateTable,
+				Object: history.Name,
+				Detail: "history enabled",
+				apply: func(c *Client) error {
+					if err := c.CreateTable(history); err != nil {
+						return err
+					}
+					return c.backfillHistory(t)
+				},
+			})
+		} else {
+			// Old versions keep the columns that were dropped from the table
+			if err := p.planColumns(history, false); err != nil {
+				return err
+			}
+			if err := p.planIndexes(history); err != nil {
+				return err
+			}
+		}
+	}
+
+	p.changes = append(p.changes, createTriggers...)
+	return nil
+}
+
+func (p *schemaPlan) planColumns(t Table, drop bool) error {
+	schema, table := p.objectSchema(t.Name)
+	rows, err := p.client.ExecSelect(
+		`SELECT name, type, "notnull", dflt_value, pk, hidden FROM pragma_table_xinfo(?, ?)`, table, schema,
+	)
+	if err != nil {
+		return fmt.Errorf("describe %q: %w", t.Name, err)
+	}
+
+	existing := make(map[string]map[string]any, len(rows))
+	for _, row := range rows {
+		existing[strings.ToLower(fmt.Sprint(row["name"]))] = row
+	}
+
+	for _, column := range t.Columns {
+		row, ok := existing[strings.ToLower(column.Name)]
+		delete(existing, strings.ToLower(column.Name))
+		if !ok {
+			p.add(SchemaChange{
+				Kind:   ChangeAddColumn,
+				Object: t.Name + "." + column.Name,
+				apply: func(c *Client) error {
+					return c.AddColumn(t.Name, column)
+				},
+			})
+			continue
+		}
+		if detail := columnDifference(t, column, row); detail != "" {
+			p.add(SchemaChange{
+				Kind:   ChangeAlterColumn,
+				Object: t.Name + "." + column.Name,
+				Detail: detail,
+				apply: func(c *Client) error {
+					return c.AlterColumn(t.Name, column)
+				},
+			})
+		}
+	}
+
+	if !drop {
+		return nil
+	}
+	for _, row := range rows {
+		name := fmt.Sprint(row["name"])
+		if _, extra := existing[strings.ToLower(name)]; !extra {
+			continue
+		}
+		p.add(SchemaChange{
+			Kind:   ChangeDropColumn,
+			Object: t.Name + "." + name,
+			Detail: "not in schema",
+			apply: func(c *Client) error {
+				return c.DropColumn(t.Name, name)
+			},
+		})
+	}
+	return nil
+}
+
+func (p *schemaPlan) planForeignKeys(t Table) error {
+	if len(t.ForeignKeys) == 0 {
+		return nil
+	}
+
+	schema, table := p.objectSchema(t.Name)
+	rows, err := p.client.ExecSelect(
+		`SELECT id, "table", "from", "to" FROM pragma_foreign_key_list(?, ?) ORDER BY id, seq`, table, schema,
+	)
+	if err != nil {
+		return fmt.Errorf("foreign keys of %q: %w", t.Name, err)
+	}
+
+	grouped := map[string]*ForeignKey{}
+	var order []string
+	for _, row := range rows {
+		id := fmt.Sprint(row["id"])
+		fk, ok := grouped[id]
+		if !ok {
+			fk = &ForeignKey{ReferenceTable: fmt.Sprint(row["table"])}
+			grouped[id] = fk
+			order = append(order, id)
+		}
+		fk.Columns = append(fk.Columns, fmt.Sprint(row["from"]))
+		if row["to"] != nil {
+			fk.ReferenceColumns = append(fk.ReferenceColumns, fmt.Sprint(row["to"]))
+		}
+	}
+	existing := map[string]bool{}
+	for _, id := range order {
+		existing[p.foreignKeySignature(*grouped[id])] = true
+	}
+
+	for _, fk := range t.ForeignKeys {
+		if existing[p.foreignKeySignature(fk)] {
+			continue
+		}
+		p.add(SchemaChange{
+			Kind:   ChangeAddForeignKey,
+			Object: fmt.Sprintf("%s (%s)", t.Name, strings.Join(fk.Columns, ", ")),
+			Detail: "references " + fk.ReferenceTable,
+			apply: func(c *Client) error {
+				return c.AddForeignKey(t.Name, fk)
+			},
+		})
+	}
+	return nil
+}
+
+func (p *schemaPlan) planIndexes(t Table) error {
+	schema, table := p.objectSchema(t.Name)
+	wanted := map[string]bool{}
+
+	for _, idx := range t.Indexes {
+		name := qualifiedName(schema, idx.Name)
+		wanted[strings.ToLower(idx.Name)] = true
+		statements := buildIndexesSQL(Table{Name: t.Name, Indexes: []Index{idx}}, p.names, false)
+		if len(statements) == 0 {
+			continue
+		}
+
+		existing, err := p.object(name, "index")
+		if err != nil {
+			return err
+		}
+		if existing != nil && sameSQL(existing.sql, statements[0]) {
+			continue
+		}
+		detail := ""
+		if existing != nil {
+			detail = "definition changed"
+			p.add(p.dropChange(ChangeDropIndex, "INDEX", name, detail))
+		}
+		p.add(SchemaChange{Kind: ChangeCreateIndex, Object: name, Detail: detail, apply: execChange(statements[0])})
+	}
+
+	if !p.opts.DropObjects {
+		return nil
+	}
+	objects, err := p.schemaObjects(schema)
+	if err != nil {
+		return err
+	}
+	for _, object := range sortedObjects(objects) {
+		// Indexes without SQL belong to PRIMARY KEY and UNIQUE constraints
+		if object.kind != "index" || object.sql == "" || !strings.EqualFold(object.table, table) || wanted[strings.ToLower(object.name)] {
+			continue
+		}
+		p.add(p.dropChange(ChangeDropIndex, "INDEX", qualifiedName(schema, object.name), "not in schema"))
+	}
+	return nil
+}
+
+// Drops are planned right away, the returned creates are left to the caller
+func (p *schemaPlan) planTriggers(triggers []Trigger, table string, drop bool) ([]SchemaChange, error) {
+	var creates []SchemaChange
+	wanted := map[string]bool{}
+	for _, tr := range triggers {
+		schema, name := p.objectSchema(tr.Name)
+		wanted[strings.ToLower(name)] = true
+		statement := buildTriggerSQL(tr, p.names, false)
+
+		existing, err := p.object(tr.Name, "trigger")
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil && sameSQL(existing.sql, statement) {
+			continue
+		}
+		detail := ""
+		if existing != nil {
+			detail = "definition changed"
+			p.add(p.dropChange(ChangeDropTrigger, "TRIGGER", qualifiedName(schema, name), detail))
+		}
+		creates = append(creates, SchemaChange{Kind: ChangeCreateTrigger, Object: tr.Name, Detail: detail, apply: execChange(statement)})
+	}
+
+	if !drop || table == "" {
+		return creates, nil
+	}
+	schema, name := p.objectSchema(table)
+	objects, err := p.schemaObjects(schema)
+	if err != nil {
+		return nil, err
+	}
+	virtual := virtualTables(objects)
+	for _, object := range sortedObjects(objects) {
+		if object.kind != "trigger" || !strings.EqualFold(object.table, name) || wanted[strings.ToLower(object.name)] {
+			continue
+		}
+		if isSpatialTrigger(object, virtual) {
+			continue
+		}
+		p.add(p.dropChange(ChangeDropTrigger, "TRIGGER", qualifiedName(schema, object.name), "not in schema"))
+	}
+	return creates, nil
+}
+
+func (p *schemaPlan) add(change SchemaChange) {
+	p.changes = append(p.changes, change)
+}
+
+func (p *schemaPlan) object(name string, kind string) (*schemaObject, error) {
+	schema, object := p.objectSchema(name)
+	objects, err := p.schemaObjects(schema)
+	if err != nil {
+		return nil, err
+	}
+	if found, ok := objects[kind+":"+strings.ToLower(object)]; ok {
+		return &found, nil
+	}
+	return nil, nil
+}
+
+func (p *schemaPlan) schemaObjects(schema string) (map[string]schemaObject, error) {
+	if objects, ok := p.objects[schema]; ok {
+		return objects, nil
+	}
+
+	rows, err := p.client.ExecSelect(fmt.Sprintf(
+		"SELECT type, name, tbl_name, sql FROM %s.sqlite_master WHERE name NOT LIKE 'sqlite_%%'", escapeIdentifier(schema),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("read schema %q: %w", schema, err)
+	}
+
+	objects := make(map[string]schemaObject, len(rows))
+	for _, row := range rows {
+		object := schemaObject{
+			kind:  fmt.Sprint(row["type"]),
+			name:  fmt.Sprint(row["name"]),
+			table: fmt.Sprint(row["tbl_name"]),
+		}
+		if row["sql"] != nil {
+			object.sql = fmt.Sprint(row["sql"])
+		}
+		objects[object.kind+":"+strings.ToLower(object.name)] = object
+	}
+	p.objects[schema] = objects
+	return objects, nil
+}
+
+func columnDifference(t Table, column Column, row map[string]any) string {
+	var differences []string
+
+	if existing := strings.TrimSpace(fmt.Sprint(row["type"])); !strings.EqualFold(existing, string(column.Type)) {
+		differences = append(differences, fmt.Sprintf("type %s -> %s", existing, column.Type))
+	}
+
+	notNull := column.NotNull != nil && *column.NotNull
+	if existing := fmt.Sprint(row["notnull"]) == "1"; existing != notNull {
+		differences = append(differences, fmt.Sprintf("not null %t -> %t", existing, notNull))
+	}
+
+	hidden := fmt.Sprint(row["hidden"])
+	generated := column.GeneratedExpr != nil
+	if existing := hidden == "2" || hidden == "3"; existing != generated {
+		differences = append(differences, fmt.Sprintf("generated %t -> %t", existing, generated))
+	}
+
+	if !generated {
+		def, existing := "", ""
+		if column.Default != nil {
+			def = strings.TrimSpace(*column.Default)
+		}
+		if row["dflt_value"] != nil {
+			existing = strings.TrimSpace(fmt.Sprint(row["dflt_value"]))
+		}
+		if def != existing {
+			differences = append(differences, fmt.Sprintf("default %q -> %q", existing, def))
+		}
+	}
+
+	// Table level keys are not compared, the column flag alone can not describe them
+	if t.PrimaryKey == nil {
+		primaryKey := column.PrimaryKey != nil && *column.PrimaryKey
+		if existing := fmt.Sprint(row["pk"]) != "0"; existing != primaryKey {
+			differences = append(differences, fmt.Sprintf("primary key %t -> %t", existing, primaryKey))
+		}
+	}
+
+	return strings.Join(differences, ", ")
+}
+
+func (p *schemaPlan) foreignKeySignature(fk ForeignKey) string {
+	_, table := p.names.split(fk.ReferenceTable)
+	return strings.ToLower(fmt.Sprintf("%s(%s)->(%s)", table, strings.Join(fk.Columns, ","), strings.Join(fk.ReferenceColumns, ",")))
+}
+
+// The stored statement keeps the text it was created with, apart from IF NOT EXISTS
+func sameSQL(stored string, statement string) bool {
+	normalize := func(sql string) string {
+		sql = strings.TrimSpace(ifNotExistsRegexp.ReplaceAllString(strings.TrimSpace(sql), "$1"))
+		return strings.Join(strings.Fields(strings.TrimSuffix(sql, ";")), " ")
+	}
+	return normalize(stored) == normalize(statement)
+}
+
+func sortedObjects(objects map[string]schemaObject) []schemaObject {
+	sorted := make([]schemaObject, 0, len(objects))
+	for _, object := range objects {
+		sorted = append(sorted, object)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return strings.ToLower(sorted[i].name) < strings.ToLower(sorted[j].name)
+	})
+	return sorted
+}
+
+// Virtual tables and their shadow tables are owned by modules such as rtree, not by the schema
+func virtualTables(objects map[string]schemaObject) []string {
+	var names []string
+	for _, object := range objects {
+		if object.kind == "table" && strings.HasPrefix(strings.ToUpper(object.sql), "CREATE VIRTUAL TABLE") {
+			names = append(names, strings.ToLower(object.name))
+		}
+	}
+	return names
+}
+
+func isVirtualTable(object schemaObject, virtual []string) bool {
+	name := strings.ToLower(object.name)
+	for _, table := range virtual {
+		if name == table || strings.HasPrefix(name, table+"_") {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateSpatialTable names the triggers that fill the index after the index
+func isSpatialTrigger(object schemaObject, virtual []string) bool {
+	name := strings.ToLower(object.name)
+	for _, table := range virtual {
+		for _, suffix := range []string{"_insert", "_update", "_delete"} {
+			if name == table+suffix {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isLibraryTable(name string) bool {
+	for _, table := range libraryTables {
+		if strings.EqualFold(name, table) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *schemaPlan) objectSchema(name string) (string, string) {
+	schema, object := p.names.split(name)
+	if schema == "" {
+		schema = "main"
+	}
+	return schema, object
+}
+
+func qualifiedName(schema string, name string) string {
+	if schema == "" || schema == "main" {
+		return name
+	}
+	return schema + "." + name
+}
+
+func (p *schemaPlan) dropChange(kind SchemaChangeKind, objectType string, name string, detail string) SchemaChange {
+	return SchemaChange{
+		Kind:   kind,
+		Object: name,
+		Detail: detail,
+		apply:  execChange(fmt.Sprintf("DROP %s IF EXISTS %s", objectType, p.names.escape(name))),
+	}
+}
+
+func execChange(statement string) func(c *Client) error {
+	return func(c *Client) error {
+		return c.Execute(statement)
+	}
+}
@@ -0,0 +1,389 @@
+package sqlite_test
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/halushko/core-go/sqlite"
+	"github.com/halushko/core-go/sqlite/sqlitetest"
+)
+
+var allDrops = sqlite.SyncOptions{DropTables: true, DropColumns: true, DropObjects: true}
+
+func TestSyncSchemaIsIdempotent(t *testing.T) {
+	client := sqlitetest.New(t)
+	schema := testSchema()
+
+	changes, err := client.SyncSchema(schema, allDrops)
+	if err != nil {
+		t.Fatalf("first sync: %v", err)
+	}
+	if len(changes) == 0 {
+		t.Fatal("first sync made no changes")
+	}
+
+	for _, opts := range []sqlite.SyncOptions{{}, allDrops} {
+		plan, err := client.PlanSchema(schema, opts)
+		if err != nil {
+			t.Fatalf("plan with %+v: %v", opts, err)
+		}
+		if len(plan) > 0 {
+			t.Errorf("plan with %+v after sync: %v", opts, plan)
+		}
+	}
+
+	changes, err = client.SyncSchema(schema, allDrops)
+	if err != nil {
+		t.Fatalf("second sync: %v", err)
+	}
+	if len(changes) > 0 {
+		t.Errorf("second sync made changes: %v", changes)
+	}
+}
+
+func TestSchemaSQLMatchesCreatedTables(t *testing.T) {
+	client := sqlitetest.New(t)
+	var tables []sqlite.Table
+	for _, table := range testSchema().Tables {
+		if err := client.CreateTable(table); err != nil {
+			t.Fatalf("create %s: %v", table.Name, err)
+		}
+		tables = append(tables, table)
+	}
+
+	got, err := client.SchemaSQL()
+	if err != nil {
+		t.Fatalf("schema sql: %v", err)
+	}
+	if want := (sqlite.Schema{Tables: tables}).SQL(); got != want {
+		t.Errorf("SchemaSQL after CreateTable:\n%s\nSchema.SQL:\n%s", got, want)
+	}
+}
+
+func TestSyncSchemaDropOptions(t *testing.T) {
+	client := sqlitetest.New(t)
+	schema := testSchema()
+	if _, err := client.SyncSchema(schema, sqlite.SyncOptions{}); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+
+	// Objects the library creates on its own must survive every drop option
+	kv, err := sqlite.NewKV(client, "sync")
+	if err != nil {
+		t.Fatalf("kv: %v", err)
+	}
+	if _, err := sqlite.NewQueue(client, "sync"); err != nil {
+		t.Fatalf("queue: %v", err)
+	}
+	if _, err := sqlite.AcquireLease(client, "sync", "test", time.Minute); err != nil {
+		t.Fatalf("lease: %v", err)
+	}
+	spatial := sqlite.SpatialTable{Name: "places_index", Table: "places", Key: "id", Latitude: "lat", Longitude: "lon"}
+	if err := client.CreateSpatialTable(spatial); err != nil {
+		t.Fatalf("spatial table: %v", err)
+	}
+
+	for _, statement := range []string{
+		"CREATE TABLE extra (id INTEGER)",
+		"ALTER TABLE users ADD COLUMN nickname TEXT",
+		"CREATE INDEX users_extra ON users (status, email)",
+		"CREATE VIEW extra_view AS SELECT 1 AS one",
+		"CREATE TRIGGER extra_trigger AFTER INSERT ON users BEGIN SELECT 1; END",
+	} {
+		if err := client.Execute(statement); err != nil {
+			t.Fatalf("%s: %v", statement, err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		opts sqlite.SyncOptions
+		want []string
+	}{
+		{name: "no drops", opts: sqlite.SyncOptions{}},
+		{name: "tables", opts: sqlite.SyncOptions{DropTables: true}, want: []string{"drop table extra"}},
+		{name: "columns", opts: sqlite.SyncOptions{DropColumns: true}, want: []string{"drop column users.nickname"}},
+		{
+			name: "objects",
+			opts: sqlite.SyncOptions{DropObjects: true},
+			want: []string{"drop index users_extra", "drop trigger extra_trigger", "drop view extra_view"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := client.PlanSchema(schema, tt.opts)
+			if err != nil {
+				t.Fatalf("plan: %v", err)
+			}
+			if got := changeNames(plan); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("plan = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := client.SyncSchema(schema, allDrops); err != nil {
+		t.Fatalf("sync with drops: %v", err)
+	}
+	plan, err := client.PlanSchema(schema, allDrops)
+	if err != nil {
+		t.Fatalf("plan after drops: %v", err)
+	}
+	if len(plan) > 0 {
+		t.Errorf("plan after drops: %v", plan)
+	}
+
+	if err := kv.Set("key", "value", 0); err != nil {
+		t.Errorf("kv after sync: %v", err)
+	}
+	if err := client.Execute("INSERT INTO places (id, lat, lon) VALUES (1, 50.45, 30.52)"); err != nil {
+		t.Fatalf("insert place: %v", err)
+	}
+	found, err := client.WithinBox(spatial, sqlite.BoundingBox{MinLat: 50, MinLon: 30, MaxLat: 51, MaxLon: 31})
+	if err != nil {
+		t.Fatalf("within box: %v", err)
+	}
+	if len(found) != 1 {
+		t.Errorf("spatial index found %d places after sync, want 1", len(found))
+	}
+}
+
+func TestLoadSchemaFormatsMatch(t *testing.T) {
+	fsys := fstest.MapFS{
+		"schema.yaml": {Data: []byte(`
+tables:
+  - name: users
+    timestamps: true
+    soft_delete: true
+    columns:
+      - {name: id, type: integer, primary_key: true, autoincrement: true}
+      - {name: email, type: text, not_null: true, unique: true, unique_on_conflict: IGNORE}
+      - {name: status, type: text, default: "'active'"}
+      - {name: score, type: real, default: 1.5}
+      - {name: email_lower, type: text, generated: lower(email), stored: true}
+    checks:
+      - {name: score_positive, expr: score >= 0}
+    indexes:
+      - {name: users_status, columns: [status], where: status IS NOT NULL}
+      - name: users_email_nocase
+        unique: true
+        keys:
+          - {column: email, collate: NOCASE, desc: true}
+    retention: {column: created_at, max_age: 720h, max_rows: 1000, batch_size: 100}
+    history: {retention: 2160h}
+  - name: memberships
+    columns:
+      - {name: user_id, type: integer}
+      - {name: team, type: text}
+    primary_key: {name: memberships_pk, columns: [user_id, team], on_conflict: REPLACE}
+    unique:
+      - {columns: [team, user_id]}
+    foreign_keys:
+      - name: memberships_user
+        columns: [user_id]
+        reference_table: users
+        reference_columns: [id]
+        on_delete: CASCADE
+        deferrable: true
+        initially_deferred: true
+views:
+  - {name: active_users, query: SELECT id FROM users WHERE deleted_at IS NULL}
+triggers:
+  - name: memberships_team
+    table: memberships
+    timing: BEFORE
+    event: UPDATE
+    columns: [team]
+    when: NEW.team = ''
+    body: SELECT RAISE(ABORT, 'team is empty');
+`)},
+		"schema.json": {Data: []byte(`{
+  "tables": [
+    {
+      "name": "users",
+      "timestamps": true,
+      "soft_delete": true,
+      "columns": [
+        {"name": "id", "type": "integer", "primary_key": true, "autoincrement": true},
+        {"name": "email", "type": "text", "not_null": true, "unique": true, "unique_on_conflict": "IGNORE"},
+        {"name": "status", "type": "text", "default": "'active'"},
+        {"name": "score", "type": "real", "default": 1.5},
+        {"name": "email_lower", "type": "text", "generated": "lower(email)", "stored": true}
+      ],
+      "checks": [{"name": "score_positive", "expr": "score >= 0"}],
+      "indexes": [
+        {"name": "users_status", "columns": ["status"], "where": "status IS NOT NULL"},
+        {"name": "users_email_nocase", "unique": true, "keys": [{"column": "email", "collate": "NOCASE", "desc": true}]}
+      ],
+      "retention": {"column": "created_at", "max_age": "720h", "max_rows": 1000, "batch_size": 100},
+      "history": {"retention": "2160h"}
+    },
+    {
+      "name": "memberships",
+      "columns": [
+        {"name": "user_id", "type": "integer"},
+        {"name": "team", "type": "text"}
+      ],
+      "primary_key": {"name": "memberships_pk", "columns": ["user_id", "team"], "on_conflict": "REPLACE"},
+      "unique": [{"columns": ["team", "user_id"]}],
+      "foreign_keys": [{
+        "name": "memberships_user",
+        "columns": ["user_id"],
+        "reference_table": "users",
+        "reference_columns": ["id"],
+        "on_delete": "CASCADE",
+        "deferrable": true,
+        "initially_deferred": true
+      }]
+    }
+  ],
+  "views": [{"name": "active_users", "query": "SELECT id FROM users WHERE deleted_at IS NULL"}],
+  "triggers": [{
+    "name": "memberships_team",
+    "table": "memberships",
+    "timing": "BEFORE",
+    "event": "UPDATE",
+    "columns": ["team"],
+    "when": "NEW.team = ''",
+    "body": "SELECT RAISE(ABORT, 'team is empty');"
+  }]
+}`)},
+	}
+
+	fromYAML, err := sqlite.LoadSchema(fsys, "schema.yaml")
+	if err != nil {
+		t.Fatalf("load yaml: %v", err)
+	}
+	fromJSON, err := sqlite.LoadSchema(fsys, "schema.json")
+	if err != nil {
+		t.Fatalf("load json: %v", err)
+	}
+	if !reflect.DeepEqual(fromYAML, fromJSON) {
+		t.Errorf("yaml and json schemas differ:\nyaml: %+v\njson: %+v", fromYAML, fromJSON)
+	}
+
+	client := sqlitetest.New(t)
+	if _, err := client.SyncSchema(fromYAML, sqlite.SyncOptions{}); err != nil {
+		t.Fatalf("sync yaml schema: %v", err)
+	}
+	plan, err := client.PlanSchema(fromJSON, allDrops)
+	if err != nil {
+		t.Fatalf("plan json schema: %v", err)
+	}
+	if len(plan) > 0 {
+		t.Errorf("json schema plans changes over the yaml one: %v", plan)
+	}
+}
+
+func TestLoadSchemaDefaultLiterals(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		yaml string
+		want string
+	}{
+		{name: "large integer", json: `12345678901234567`, yaml: `12345678901234567`, want: "12345678901234567"},
+		{name: "negative integer", json: `-42`, yaml: `-42`, want: "-42"},
+		{name: "exponent", json: `1e21`, yaml: `1e21`, want: "1000000000000000000000"},
+		{name: "fraction", json: `0.1`, yaml: `0.1`, want: "0.1"},
+		{name: "boolean", json: `true`, yaml: `true`, want: "true"},
+		{name: "text", json: `"'it''s'"`, yaml: `"'it''s'"`, want: "'it''s'"},
+		{name: "expression", json: `"CURRENT_TIMESTAMP"`, yaml: `CURRENT_TIMESTAMP`, want: "CURRENT_TIMESTAMP"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := fstest.MapFS{
+				"schema.json": {Data: []byte(fmt.Sprintf(`{"tables": [{"name": "t", "columns": [{"name": "c", "type": "text", "default": %s}]}]}`, tt.json))},
+				"schema.yaml": {Data: []byte(fmt.Sprintf("tables:\n  - name: t\n    columns:\n      - {name: c, type: text, default: %s}\n", tt.yaml))},
+			}
+			for _, file := range []string{"schema.json", "schema.yaml"} {
+				schema, err := sqlite.LoadSchema(fsys, file)
+				if err != nil {
+					t.Fatalf("load %s: %v", file, err)
+				}
+				got := schema.Tables[0].Columns[0].Default
+				if got == nil || *got != tt.want {
+					t.Errorf("%s default = %v, want %q", file, valueOf(got), tt.want)
+				}
+			}
+		})
+	}
+}
+
+func testSchema() sqlite.Schema {
+	return sqlite.Schema{
+		Tables: []sqlite.Table{
+			{
+				Name: "users",
+				Columns: []sqlite.Column{
+					{Name: "id", Type: sqlite.TypeInteger, PrimaryKey: boolPtr(true), AutoIncrement: boolPtr(true)},
+					{Name: "email", Type: sqlite.TypeText, NotNull: boolPtr(true), Unique: boolPtr(true)},
+					{Name: "status", Type: sqlite.TypeText, Default: stringPtr("'active'")},
+				},
+				Indexes:    []sqlite.Index{{Name: "users_status", Columns: []string{"status"}}},
+				Timestamps: boolPtr(true),
+				SoftDelete: boolPtr(true),
+			},
+			{
+				Name: "posts",
+				Columns: []sqlite.Column{
+					{Name: "id", Type: sqlite.TypeInteger, PrimaryKey: boolPtr(true)},
+					{Name: "user_id", Type: sqlite.TypeInteger, NotNull: boolPtr(true)},
+					{Name: "title", Type: sqlite.TypeText},
+				},
+				ForeignKeys: []sqlite.ForeignKey{
+					{Columns: []string{"user_id"}, ReferenceTable: "users", ReferenceColumns: []string{"id"}, OnDelete: "CASCADE"},
+				},
+				History: &sqlite.History{},
+			},
+			{
+				Name: "places",
+				Columns: []sqlite.Column{
+					{Name: "id", Type: sqlite.TypeInteger, PrimaryKey: boolPtr(true)},
+					{Name: "lat", Type: sqlite.TypeReal},
+					{Name: "lon", Type: sqlite.TypeReal},
+				},
+			},
+		},
+		Views: []sqlite.View{
+			{Name: "active_users", Query: "SELECT id, email FROM users WHERE deleted_at IS NULL"},
+		},
+		Triggers: []sqlite.Trigger{
+			{
+				Name:   "posts_title",
+				Table:  "posts",
+				Timing: "BEFORE",
+				Event:  "INSERT",
+				When:   "NEW.title = ''",
+				Body:   "SELECT RAISE(ABORT, 'title is empty');",
+			},
+		},
+	}
+}
+
+func changeNames(changes []sqlite.SchemaChange) []string {
+	var names []string
+	for _, change := range changes {
+		names = append(names, fmt.Sprintf("%s %s", change.Kind, change.Object))
+	}
+	sort.Strings(names)
+	return names
+}
+
+func valueOf(s *string) string {
+	if s == nil {
+		return "<nil>"
+	}
+	return *s
+}
+
+func boolPtr(v bool) *bool {
+	return &v
+}
+
+func stringPtr(v string) *string {
+	return &v
+}
@@ -0,0 +1,72 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+func (c *Client) SelectLive(table string, where string, args ...any) ([]map[string]any, error) {
+	if table == "" {
+		return nil, errors.New("table name is empty")
+	}
+
+	query := fmt.Sprintf("SELECT * FROM %s WHERE %s IS NULL", c.schemas().escape(table), escapeIdentifier(ColumnDeletedAt))
+	if where != "" {
+		query += " AND (" + where + ")"
+	}
+	return c.ExecSelect(query, args...)
+}
+
+func (c *Client) SelectWithDeleted(table string, where string, args ...any) ([]map[string]any, error) {
+	if table == "" {
+		return nil, errors.New("table name is empty")
+	}
+
+	query := fmt.Sprintf("SELECT * FROM %s", c.schemas().escape(table))
+	if where != "" {
+		query += " WHERE " + where
+	}
+	return c.ExecSelect(query, args...)
+}
+
+func (c *Client) SelectDeleted(table string, where string, args ...any) ([]map[string]any, error) {
+	if table == "" {
+		return nil, errors.New("table name is empty")
+	}
+
+	query := fmt.Sprintf("SELECT * FROM %s WHERE %s IS NOT NULL", c.schemas().escape(table), escapeIdentifier(ColumnDeletedAt))
+	if where != "" {
+		query += " AND (" + where + ")"
+	}
+	return c.ExecSelect(query, args...)
+}
+
+func (c *Client) PurgeDeleted(table string, retention time.Duration) (int64, error) {
+	if c == nil || c.db == nil {
+		return 0, errors.New("db client is nil")
+	}
+	if table == "" {
+		return 0, errors.New("table name is empty")
+	}
+	if retention < 0 {
+		return 0, fmt.Errorf("invalid retention: %s", retention)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	query := fmt.Sprintf(
+		"DELETE FROM %s WHERE %s IS NOT NULL AND %s <= datetime('now', ?)",
+		c.schemas().escape(table),
+		escapeIdentifier(ColumnDeletedAt),
+		escapeIdentifier(ColumnDeletedAt),
+	)
+	result, err := c.executeContext(ctx, query, fmt.Sprintf("-%d seconds", int64(retention.Seconds())))
+	if err != nil {
+		return 0, fmt.Errorf("purge deleted rows of %q: %w", table, err)
+	}
+
+	return result.RowsAffected()
+}
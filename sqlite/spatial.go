@@ -0,0 +1,366 @@
+package sqlite
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	engine "modernc.org/sqlite"
+)
+
+const (
+	ColumnDistance = "distance"
+
+	earthRadiusMeters       = 6371008.8
+	defaultNearestRadius    = 1000.0
+	nearestRadiusGrowth     = 4.0
+	spatialRowid            = "rowid"
+	haversineFunctionName   = "haversine"
+	haversineFunctionParams = 4
+)
+
+var (
+	registerFunctionsOnce sync.Once
+	registerFunctionsErr  error
+)
+
+// R*Tree index of point coordinates kept in sync with the base table by triggers
+type SpatialTable struct {
+	Name      string
+	Table     string
+	Key       string
+	Latitude  string
+	Longitude string
+}
+
+// A box with MinLon greater than MaxLon crosses the antimeridian
+type BoundingBox struct {
+	MinLat float64
+	MinLon float64
+	MaxLat float64
+	MaxLon float64
+}
+
+func (c *Client) CreateSpatialTable(s SpatialTable) error {
+	if c == nil || c.db == nil {
+		return errors.New("db client is nil")
+	}
+	names := c.schemas()
+	if err := s.validate(names); err != nil {
+		return fmt.Errorf("invalid spatial table %q: %w", s.Name, err)
+	}
+
+	for _, statement := range s.statements(names, true) {
+		if err := c.Execute(statement); err != nil {
+			return fmt.Errorf("create spatial table %q: %w", s.Name, err)
+		}
+	}
+	return nil
+}
+
+func (s SpatialTable) Validate() error {
+	return s.validate(nil)
+}
+
+func (s SpatialTable) validate(names schemaNames) error {
+	var errs []error
+	if strings.TrimSpace(s.Name) == "" {
+		errs = append(errs, errors.New("spatial table name is empty"))
+	}
+	if strings.TrimSpace(s.Table) == "" {
+		errs = append(errs, errors.New("base table name is empty"))
+	}
+	if strings.TrimSpace(s.Latitude) == "" || strings.TrimSpace(s.Longitude) == "" {
+		errs = append(errs, errors.New("latitude and longitude columns are required"))
+	}
+
+	schema, _ := names.split(s.Name)
+	tableSchema, _ := names.split(s.Table)
+	if !strings.EqualFold(schema, tableSchema) {
+		errs = append(errs, fmt.Errorf("spatial table and base table %q are in different schemas", s.Table))
+	}
+	return errors.Join(errs...)
+}
+
+func (s SpatialTable) SQL(opts SQLOptions) string {
+	return joinStatements(s.statements(nil, opts.IfNotExists))
+}
+
+func (c *Client) WithinBox(s SpatialTable, box BoundingBox) ([]map[string]any, error) {
+	if c == nil || c.db == nil {
+		return nil, errors.New("db client is nil")
+	}
+	names := c.schemas()
+	if err := s.validate(names); err != nil {
+		return nil, fmt.Errorf("invalid spatial table %q: %w", s.Name, err)
+	}
+	if err := box.validate(); err != nil {
+		return nil, err
+	}
+
+	where, args := s.boxCondition(box)
+	query := fmt.Sprintf("SELECT base.* FROM %s WHERE %s", s.join(names), where)
+
+	rows, err := c.ExecSelect(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("points of %q within box: %w", s.Table, err)
+	}
+	return rows, nil
+}
+
+// Rows get a distance column in meters that takes the place of a base column with the same name,
+// maxDistance 0 searches the whole globe
+func (c *Client) Nearest(s SpatialTable, lat float64, lon float64, limit int, maxDistance float64) ([]map[string]any, error) {
+	if c == nil || c.db == nil {
+		return nil, errors.New("db client is nil")
+	}
+	names := c.schemas()
+	if err := s.validate(names); err != nil {
+		return nil, fmt.Errorf("invalid spatial table %q: %w", s.Name, err)
+	}
+	if err := validatePoint(lat, lon); err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		return nil, fmt.Errorf("invalid limit: %d", limit)
+	}
+	if maxDistance < 0 {
+		return nil, fmt.Errorf("invalid max distance: %f", maxDistance)
+	}
+	if err := registerFunctions(); err != nil {
+		return nil, fmt.Errorf("nearest points of %q: %w", s.Table, err)
+	}
+
+	// Grows the searched box until it holds enough points, everything outside the circle is farther away
+	radius := defaultNearestRadius
+	if maxDistance > 0 && maxDistance < radius {
+		radius = maxDistance
+	}
+	for {
+		box, whole := boxAround(lat, lon, radius)
+		where, args := s.boxCondition(box)
+		distance := fmt.Sprintf("%s(base.%s, base.%s, ?, ?)", haversineFunctionName, escapeIdentifier(s.Latitude), escapeIdentifier(s.Longitude))
+
+		// Ordered by the expression, the alias would resolve to a base column of the same name
+		query := fmt.Sprintf(
+			"SELECT base.*, %s AS %s FROM %s WHERE %s AND %s <= ? ORDER BY %s LIMIT %d",
+			distance, escapeIdentifier(ColumnDistance), s.join(names), where, distance, distance, limit,
+		)
+		args = append([]any{lat, lon}, args...)
+		args = append(args, lat, lon, radius, lat, lon)
+
+		rows, err := c.ExecSelect(query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("nearest points of %q: %w", s.Table, err)
+		}
+		if len(rows) >= limit || whole || (maxDistance > 0 && radius >= maxDistance) {
+			return rows, nil
+		}
+
+		radius *= nearestRadiusGrowth
+		if maxDistance > 0 && radius > maxDistance {
+			radius = maxDistance
+		}
+	}
+}
+
+func (s SpatialTable) statements(names schemaNames, ifNotExists bool) []string {
+	createClause := "CREATE VIRTUAL TABLE"
+	if ifNotExists {
+		createClause += " IF NOT EXISTS"
+	}
+
+	schema, name := names.split(s.Name)
+	_, table := names.split(s.Table)
+	triggerName := func(suffix string) string {
+		if schema != "" {
+			return schema + "." + name + "_" + suffix
+		}
+		return name + "_" + suffix
+	}
+
+	index := escapeIdentifier(name)
+	key, lat, lon := s.key(), escapeIdentifier(s.Latitude), escapeIdentifier(s.Longitude)
+	point := func(row string) string {
+		return fmt.Sprintf("%s.%s, %s.%s, %s.%s, %s.%s, %s.%s", row, key, row, lat, row, lat, row, lon, row, lon)
+	}
+	located := func(row string) string {
+		return fmt.Sprintf("%s.%s IS NOT NULL AND %s.%s IS NOT NULL", row, lat, row, lon)
+	}
+
+	updated := []string{s.Latitude, s.Longitude}
+	if s.Key != "" && !strings.EqualFold(s.Key, spatialRowid) {
+		updated = append(updated, s.Key)
+	}
+	remove := fmt.Sprintf("DELETE FROM %s WHERE id = OLD.%s;", index, key)
+
+	statements := []string{
+		fmt.Sprintf("%s %s USING rtree(id, min_lat, max_lat, min_lon, max_lon);", createClause, names.escape(s.Name)),
+		fmt.Sprintf(
+			"INSERT OR REPLACE INTO %s SELECT %s FROM %s AS base WHERE %s;",
+			names.escape(s.Name), point("base"), names.escape(s.Table), located("base"),
+		),
+	}
+	for _, trigger := range []Trigger{
+		{
+			Name:   triggerName("insert"),
+			Table:  table,
+			Timing: "AFTER",
+			Event:  "INSERT",
+			When:   located("NEW"),
+			Body:   fmt.Sprintf("INSERT OR REPLACE INTO %s VALUES (%s);", index, point("NEW")),
+		},
+		{
+			Name:    triggerName("update"),
+			Table:   table,
+			Timing:  "AFTER",
+			Event:   "UPDATE",
+			Columns: updated,
+			Body:    remove + fmt.Sprintf("\nINSERT OR REPLACE INTO %s SELECT %s WHERE %s;", index, point("NEW"), located("NEW")),
+		},
+		{
+			Name:   triggerName("delete"),
+			Table:  table,
+			Timing: "AFTER",
+			Event:  "DELETE",
+			Body:   remove,
+		},
+	} {
+		statements = append(statements, buildTriggerSQL(trigger, names, ifNotExists))
+	}
+	return statements
+}
+
+func (s SpatialTable) key() string {
+	if s.Key == "" || strings.EqualFold(s.Key, spatialRowid) {
+		return spatialRowid
+	}
+	return escapeIdentifier(s.Key)
+}
+
+func (s SpatialTable) join(names schemaNames) string {
+	return fmt.Sprintf(
+		"%s AS base JOIN %s AS spatial ON spatial.id = base.%s",
+		names.escape(s.Table), names.escape(s.Name), s.key(),
+	)
+}
+
+// The R*Tree stores rounded 32-bit coordinates, the base columns give the exact answer
+func (s SpatialTable) boxCondition(box BoundingBox) (string, []any) {
+	lat, lon := "base."+escapeIdentifier(s.Latitude), "base."+escapeIdentifier(s.Longitude)
+
+	where := "spatial.min_lat <= ? AND spatial.max_lat >= ? AND " + lat + " BETWEEN ? AND ?"
+	args := []any{box.MaxLat, box.MinLat, box.MinLat, box.MaxLat}
+
+	lonRange := func(minLon float64, maxLon float64) (string, []any) {
+		return "(spatial.min_lon <= ? AND spatial.max_lon >= ? AND " + lon + " BETWEEN ? AND ?)", []any{maxLon, minLon, minLon, maxLon}
+	}
+	if box.MinLon <= box.MaxLon {
+		condition, lonArgs := lonRange(box.MinLon, box.MaxLon)
+		return where + " AND " + condition, append(args, lonArgs...)
+	}
+
+	east, eastArgs := lonRange(box.MinLon, 180)
+	west, westArgs := lonRange(-180, box.MaxLon)
+	args = append(args, eastArgs...)
+	return where + " AND (" + east + " OR " + west + ")", append(args, westArgs...)
+}
+
+func (b BoundingBox) validate() error {
+	if err := validatePoint(b.MinLat, b.MinLon); err != nil {
+		return err
+	}
+	if err := validatePoint(b.MaxLat, b.MaxLon); err != nil {
+		return err
+	}
+	if b.MinLat > b.MaxLat {
+		return fmt.Errorf("invalid bounding box: min latitude %f is above max latitude %f", b.MinLat, b.MaxLat)
+	}
+	return nil
+}
+
+// The second result reports that the box already spans the whole globe
+func boxAround(lat float64, lon float64, radius float64) (BoundingBox, bool) {
+	delta := radius / earthRadiusMeters * 180 / math.Pi
+	box := BoundingBox{MinLat: lat - delta, MaxLat: lat + delta, MinLon: -180, MaxLon: 180}
+
+	// A circle around a pole covers every longitude
+	if box.MinLat <= -90 || box.MaxLat >= 90 {
+		box.MinLat, box.MaxLat = math.Max(box.MinLat, -90), math.Min(box.MaxLat, 90)
+		return box, box.MinLat == -90 && box.MaxLat == 90
+	}
+
+	lonDelta := math.Asin(math.Min(1, math.Sin(radius/earthRadiusMeters)/math.Cos(lat*math.Pi/180))) * 180 / math.Pi
+	if lonDelta >= 180 || radius >= math.Pi*earthRadiusMeters/2 {
+		return box, false
+	}
+
+	box.MinLon, box.MaxLon = wrapLongitude(lon-lonDelta), wrapLongitude(lon+lonDelta)
+	return box, false
+}
+
+func wrapLongitude(lon float64) float64 {
+	switch {
+	case lon < -180:
+		return lon + 360
+	case lon > 180:
+		return lon - 360
+	}
+	return lon
+}
+
+func validatePoint(lat float64, lon float64) error {
+	if math.IsNaN(lat) || lat < -90 || lat > 90 {
+		return fmt.Errorf("invalid latitude: %f", lat)
+	}
+	if math.IsNaN(lon) || lon < -180 || lon > 180 {
+		return fmt.Errorf("invalid longitude: %f", lon)
+	}
+	return nil
+}
+
+func haversine(lat1 float64, lon1 float64, lat2 float64, lon2 float64) float64 {
+	toRadians := math.Pi / 180
+	dLat := (lat2 - lat1) * toRadians
+	dLon := (lon2 - lon1) * toRadians
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*toRadians)*math.Cos(lat2*toRadians)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusMeters * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// Functions registered on the driver are installed on every connection opened afterwards.
+// A haversine registered there before is kept, it has to return meters as well
+func registerFunctions() error {
+	registerFunctionsOnce.Do(func() {
+		err := engine.RegisterDeterministicScalarFunction(
+			haversineFunctionName,
+			haversineFunctionParams,
+			func(_ *engine.FunctionContext, args []driver.Value) (driver.Value, error) {
+				coordinates := make([]float64, len(args))
+				for i, arg := range args {
+					switch v := arg.(type) {
+					case float64:
+						coordinates[i] = v
+					case int64:
+						coordinates[i] = float64(v)
+					case nil:
+						return nil, nil
+					default:
+						return nil, fmt.Errorf("%s: argument %d is not a number", haversineFunctionName, i+1)
+					}
+				}
+				return haversine(coordinates[0], coordinates[1], coordinates[2], coordinates[3]), nil
+			},
+		)
+		if err != nil && !strings.Contains(err.Error(), "already registered") {
+			registerFunctionsErr = fmt.Errorf("register sql function %s: %w", haversineFunctionName, err)
+			log.Warnf("Nearest is unavailable: %v", registerFunctionsErr)
+		}
+	})
+	return registerFunctionsErr
+}
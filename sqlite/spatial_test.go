@@ -0,0 +1,44 @@
+package sqlite_test
+
+import (
+	"testing"
+
+	"github.com/halushko/core-go/sqlite"
+	"github.com/halushko/core-go/sqlite/sqlitetest"
+)
+
+var places = sqlite.Table{
+	Name: "places",
+	Columns: []sqlite.Column{
+		{Name: "id", Type: sqlite.TypeInteger, PrimaryKey: boolPtr(true)},
+		{Name: "lat", Type: sqlite.TypeReal},
+		{Name: "lon", Type: sqlite.TypeReal},
+		{Name: "distance", Type: sqlite.TypeReal},
+	},
+}
+
+var placesGeo = sqlite.SpatialTable{Name: "places_geo", Table: "places", Latitude: "lat", Longitude: "lon"}
+
+func TestNearestOrdersByComputedDistance(t *testing.T) {
+	client := sqlitetest.New(t, places)
+	if err := client.CreateSpatialTable(placesGeo); err != nil {
+		t.Fatalf("create spatial table: %v", err)
+	}
+	sqlitetest.Exec(t, client, "INSERT INTO places VALUES (1, 50.0, 30.0, 1), (2, 50.001, 30.0, 1000000), (3, 50.1, 30.0, 5)")
+
+	rows, err := client.Nearest(placesGeo, 50.0, 30.0, 3, 0)
+	if err != nil {
+		t.Fatalf("nearest: %v", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("expected 3 rows, got %v", rows)
+	}
+	for i, id := range []int64{1, 2, 3} {
+		if rows[i]["id"] != id {
+			t.Fatalf("expected id %d at %d, got %v", id, i, rows)
+		}
+	}
+	if distance, _ := rows[1]["distance"].(float64); distance < 100 || distance > 120 {
+		t.Fatalf("expected the computed distance of about 111 m, got %v", rows[1]["distance"])
+	}
+}
@@ -0,0 +1,360 @@
+package sqlitefake
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"strings"
+	"time"
+
+	"github.com/halushko/core-go/sqlite"
+)
+
+func (f *Fake) Execute(query string, args ...any) error {
+	_, err := f.call(true, Call{Method: "Execute", Subject: query, Args: args})
+	return err
+}
+
+func (f *Fake) ExecuteNamed(query string, params map[string]any) error {
+	_, err := f.call(true, Call{Method: "ExecuteNamed", Subject: query, Params: params})
+	return err
+}
+
+func (f *Fake) ExecuteSqlFile(path string, args ...any) error {
+	_, err := f.call(true, Call{Method: "ExecuteSqlFile", Subject: path, Args: args})
+	return err
+}
+
+func (f *Fake) ExecuteSqlFileNamed(path string, params map[string]any) error {
+	_, err := f.call(true, Call{Method: "ExecuteSqlFileNamed", Subject: path, Params: params})
+	return err
+}
+
+func (f *Fake) ExecSelect(query string, args ...any) ([]map[string]any, error) {
+	return f.rows(Call{Method: "ExecSelect", Subject: query, Args: args})
+}
+
+func (f *Fake) ExecSelectNamed(query string, params map[string]any) ([]map[string]any, error) {
+	return f.rows(Call{Method: "ExecSelectNamed", Subject: query, Params: params})
+}
+
+func (f *Fake) ExecSelectWithTimeout(query string, timeout time.Duration, args ...any) ([]map[string]any, error) {
+	return f.rows(Call{Method: "ExecSelectWithTimeout", Subject: query, Args: args})
+}
+
+func (f *Fake) ExecSelectWithTimeoutNamed(query string, timeout time.Duration, params map[string]any) ([]map[string]any, error) {
+	return f.rows(Call{Method: "ExecSelectWithTimeoutNamed", Subject: query, Params: params})
+}
+
+func (f *Fake) ExecSelectResult(query string, args ...any) (sqlite.Result, error) {
+	return f.result(Call{Method: "ExecSelectResult", Subject: query, Args: args})
+}
+
+func (f *Fake) ExecSelectResultNamed(query string, params map[string]any) (sqlite.Result, error) {
+	return f.result(Call{Method: "ExecSelectResultNamed", Subject: query, Params: params})
+}
+
+func (f *Fake) ExecSelectResultWithTimeout(query string, timeout time.Duration, args ...any) (sqlite.Result, error) {
+	return f.result(Call{Method: "ExecSelectResultWithTimeout", Subject: query, Args: args})
+}
+
+func (f *Fake) ExecSelectSqlFile(path string, args ...any) ([]map[string]any, error) {
+	return f.rows(Call{Method: "ExecSelectSqlFile", Subject: path, Args: args})
+}
+
+func (f *Fake) ExecSelectSqlFileNamed(path string, params map[string]any) ([]map[string]any, error) {
+	return f.rows(Call{Method: "ExecSelectSqlFileNamed", Subject: path, Params: params})
+}
+
+func (f *Fake) ExecSelectSqlFileWithTimeout(path string, timeout time.Duration, args ...any) ([]map[string]any, error) {
+	return f.rows(Call{Method: "ExecSelectSqlFileWithTimeout", Subject: path, Args: args})
+}
+
+func (f *Fake) ExecSelectSqlFileWithTimeoutNamed(path string, timeout time.Duration, params map[string]any) ([]map[string]any, error) {
+	return f.rows(Call{Method: "ExecSelectSqlFileWithTimeoutNamed", Subject: path, Params: params})
+}
+
+// Scripted with ReturnValue(sqlite.Page{...}), plain Return rows become a single page
+func (f *Fake) SelectPage(query string, orderBy []sqlite.OrderKey, cursor string, limit int, args ...any) (sqlite.Page, error) {
+	return f.page(Call{Method: "SelectPage", Subject: query, Args: append([]any{orderBy, cursor, limit}, args...)})
+}
+
+func (f *Fake) SelectPageNamed(query string, orderBy []sqlite.OrderKey, cursor string, limit int, params map[string]any) (sqlite.Page, error) {
+	return f.page(Call{Method: "SelectPageNamed", Subject: query, Args: []any{orderBy, cursor, limit}, Params: params})
+}
+
+// Interceptors are recorded but not run, scripted results stand for the whole chain
+func (f *Fake) Use(interceptor sqlite.Interceptor) error {
+	_, err := f.call(false, Call{Method: "Use"})
+	return err
+}
+
+func (f *Fake) Transaction(fn func(tx sqlite.TxDBI) error) error {
+	return f.transaction(Call{Method: "Transaction"}, fn)
+}
+
+func (f *Fake) TransactionWithTimeout(timeout time.Duration, fn func(tx sqlite.TxDBI) error) error {
+	return f.transaction(Call{Method: "TransactionWithTimeout", Args: []any{timeout}}, fn)
+}
+
+func (f *Fake) LoadFixtures(fsys fs.FS, files ...string) error {
+	_, err := f.call(false, Call{Method: "LoadFixtures", Subject: strings.Join(files, ", ")})
+	return err
+}
+
+func (f *Fake) LoadFixturesWithMode(fsys fs.FS, mode sqlite.FixtureMode, files ...string) error {
+	_, err := f.call(false, Call{Method: "LoadFixturesWithMode", Subject: strings.Join(files, ", "), Args: []any{mode}})
+	return err
+}
+
+// Scripted with ReturnValue of the exported text
+func (f *Fake) ExportTable(ctx context.Context, table string, w io.Writer, format sqlite.DataFormat) error {
+	e, err := f.call(false, Call{Method: "ExportTable", Subject: table, Args: []any{format}})
+	if err != nil {
+		return err
+	}
+	return write(w, e)
+}
+
+func (f *Fake) ExportQuery(ctx context.Context, w io.Writer, format sqlite.DataFormat, query string, args ...any) error {
+	e, err := f.call(true, Call{Method: "ExportQuery", Subject: query, Args: append([]any{format}, args...)})
+	if err != nil {
+		return err
+	}
+	return write(w, e)
+}
+
+func (f *Fake) ImportTable(ctx context.Context, table string, r io.Reader, format sqlite.DataFormat, opts sqlite.ImportOptions) error {
+	_, err := f.call(false, Call{Method: "ImportTable", Subject: table, Args: []any{format, opts}})
+	return err
+}
+
+func (f *Fake) Dump(w io.Writer) error {
+	e, err := f.call(false, Call{Method: "Dump"})
+	if err != nil {
+		return err
+	}
+	return write(w, e)
+}
+
+func (f *Fake) CreateTable(t sqlite.Table) error {
+	_, err := f.call(false, Call{Method: "CreateTable", Subject: t.Name, Args: []any{t}})
+	return err
+}
+
+func (f *Fake) DropTable(name string) error {
+	_, err := f.call(false, Call{Method: "DropTable", Subject: name})
+	return err
+}
+
+func (f *Fake) TruncateTable(name string) error {
+	_, err := f.call(false, Call{Method: "TruncateTable", Subject: name})
+	return err
+}
+
+func (f *Fake) AsOf(table string, at time.Time) ([]map[string]any, error) {
+	return f.rows(Call{Method: "AsOf", Subject: table, Args: []any{at}})
+}
+
+func (f *Fake) History(table string, pk ...any) ([]map[string]any, error) {
+	return f.rows(Call{Method: "History", Subject: table, Args: pk})
+}
+
+func (f *Fake) SelectLive(table string, where string, args ...any) ([]map[string]any, error) {
+	return f.rows(Call{Method: "SelectLive", Subject: table, Args: append([]any{where}, args...)})
+}
+
+func (f *Fake) SelectWithDeleted(table string, where string, args ...any) ([]map[string]any, error) {
+	return f.rows(Call{Method: "SelectWithDeleted", Subject: table, Args: append([]any{where}, args...)})
+}
+
+func (f *Fake) SelectDeleted(table string, where string, args ...any) ([]map[string]any, error) {
+	return f.rows(Call{Method: "SelectDeleted", Subject: table, Args: append([]any{where}, args...)})
+}
+
+func (f *Fake) PurgeDeleted(table string, retention time.Duration) (int64, error) {
+	e, err := f.call(false, Call{Method: "PurgeDeleted", Subject: table, Args: []any{retention}})
+	return valueOf[int64](e), err
+}
+
+func (f *Fake) DescribeTable(name string) ([]sqlite.Column, error) {
+	e, err := f.call(false, Call{Method: "DescribeTable", Subject: name})
+	return valueOf[[]sqlite.Column](e), err
+}
+
+func (f *Fake) RenameTable(name string, newName string) error {
+	_, err := f.call(false, Call{Method: "RenameTable", Subject: name, Args: []any{newName}})
+	return err
+}
+
+func (f *Fake) AddColumn(table string, column sqlite.Column) error {
+	_, err := f.call(false, Call{Method: "AddColumn", Subject: table, Args: []any{column}})
+	return err
+}
+
+func (f *Fake) RenameColumn(table string, column string, newName string) error {
+	_, err := f.call(false, Call{Method: "RenameColumn", Subject: table, Args: []any{column, newName}})
+	return err
+}
+
+func (f *Fake) DropColumn(table string, column string) error {
+	_, err := f.call(false, Call{Method: "DropColumn", Subject: table, Args: []any{column}})
+	return err
+}
+
+func (f *Fake) AlterColumn(table string, column sqlite.Column) error {
+	_, err := f.call(false, Call{Method: "AlterColumn", Subject: table, Args: []any{column}})
+	return err
+}
+
+func (f *Fake) AddForeignKey(table string, fk sqlite.ForeignKey) error {
+	_, err := f.call(false, Call{Method: "AddForeignKey", Subject: table, Args: []any{fk}})
+	return err
+}
+
+func (f *Fake) CreateSpatialTable(s sqlite.SpatialTable) error {
+	_, err := f.call(false, Call{Method: "CreateSpatialTable", Subject: s.Name, Args: []any{s}})
+	return err
+}
+
+func (f *Fake) WithinBox(s sqlite.SpatialTable, box sqlite.BoundingBox) ([]map[string]any, error) {
+	return f.rows(Call{Method: "WithinBox", Subject: s.Table, Args: []any{box}})
+}
+
+func (f *Fake) Nearest(s sqlite.SpatialTable, lat float64, lon float64, limit int, maxDistance float64) ([]map[string]any, error) {
+	return f.rows(Call{Method: "Nearest", Subject: s.Table, Args: []any{lat, lon, limit, maxDistance}})
+}
+
+func (f *Fake) SchemaSQL() (string, error) {
+	e, err := f.call(false, Call{Method: "SchemaSQL"})
+	return valueOf[string](e), err
+}
+
+func (f *Fake) ApplySchema(s sqlite.Schema) error {
+	_, err := f.call(false, Call{Method: "ApplySchema", Args: []any{s}})
+	return err
+}
+
+func (f *Fake) PlanSchema(s sqlite.Schema, opts sqlite.SyncOptions) ([]sqlite.SchemaChange, error) {
+	e, err := f.call(false, Call{Method: "PlanSchema", Args: []any{s, opts}})
+	return valueOf[[]sqlite.SchemaChange](e), err
+}
+
+func (f *Fake) SyncSchema(s sqlite.Schema, opts sqlite.SyncOptions) ([]sqlite.SchemaChange, error) {
+	e, err := f.call(false, Call{Method: "SyncSchema", Args: []any{s, opts}})
+	return valueOf[[]sqlite.SchemaChange](e), err
+}
+
+func (f *Fake) Explain(query string, args ...any) ([]*sqlite.PlanNode, error) {
+	e, err := f.call(true, Call{Method: "Explain", Subject: query, Args: args})
+	return valueOf[[]*sqlite.PlanNode](e), err
+}
+
+func (f *Fake) SetDevMode(scanThreshold int64) {
+	_, _ = f.call(false, Call{Method: "SetDevMode", Args: []any{scanThreshold}})
+}
+
+func (f *Fake) EnableCache(opts sqlite.CacheOptions) error {
+	_, err := f.call(false, Call{Method: "EnableCache", Args: []any{opts}})
+	return err
+}
+
+func (f *Fake) DisableCache() {
+	_, _ = f.call(false, Call{Method: "DisableCache"})
+}
+
+func (f *Fake) CacheStats() sqlite.CacheStats {
+	e, _ := f.call(false, Call{Method: "CacheStats"})
+	return valueOf[sqlite.CacheStats](e)
+}
+
+func (f *Fake) InvalidateCache(tables ...string) {
+	_, _ = f.call(false, Call{Method: "InvalidateCache", Subject: strings.Join(tables, ", ")})
+}
+
+func (f *Fake) ListIndexes(table string) ([]sqlite.IndexInfo, error) {
+	e, err := f.call(false, Call{Method: "ListIndexes", Subject: table})
+	return valueOf[[]sqlite.IndexInfo](e), err
+}
+
+func (f *Fake) DropIndex(name string) error {
+	_, err := f.call(false, Call{Method: "DropIndex", Subject: name})
+	return err
+}
+
+func (f *Fake) Reindex(name string) error {
+	_, err := f.call(false, Call{Method: "Reindex", Subject: name})
+	return err
+}
+
+func (f *Fake) Analyze(name string) error {
+	_, err := f.call(false, Call{Method: "Analyze", Subject: name})
+	return err
+}
+
+func (f *Fake) SetRetention(table string, rule sqlite.Retention) error {
+	_, err := f.call(false, Call{Method: "SetRetention", Subject: table, Args: []any{rule}})
+	return err
+}
+
+func (f *Fake) RemoveRetention(table string) {
+	_, _ = f.call(false, Call{Method: "RemoveRetention", Subject: table})
+}
+
+func (f *Fake) PurgeExpired(ctx context.Context) (map[string]int64, error) {
+	e, err := f.call(false, Call{Method: "PurgeExpired"})
+	return valueOf[map[string]int64](e), err
+}
+
+func (f *Fake) StartMaintenance(opts sqlite.MaintenanceOptions) error {
+	_, err := f.call(false, Call{Method: "StartMaintenance", Args: []any{opts}})
+	return err
+}
+
+func (f *Fake) StopMaintenance() {
+	_, _ = f.call(false, Call{Method: "StopMaintenance"})
+}
+
+func (f *Fake) RunMaintenance(ctx context.Context, task sqlite.MaintenanceTask) (sqlite.MaintenanceReport, error) {
+	e, err := f.call(false, Call{Method: "RunMaintenance", Subject: string(task)})
+	report := valueOf[sqlite.MaintenanceReport](e)
+	if report.Task == "" {
+		report.Task = task
+	}
+	return report, err
+}
+
+func (f *Fake) Attach(alias string, otherDbName string, readOnly bool) error {
+	_, err := f.call(false, Call{Method: "Attach", Subject: alias, Args: []any{otherDbName, readOnly}})
+	return err
+}
+
+func (f *Fake) Detach(alias string) error {
+	_, err := f.call(false, Call{Method: "Detach", Subject: alias})
+	return err
+}
+
+// The text given with ReturnValue, otherwise the whole name as one identifier
+func (f *Fake) QuoteName(name string) string {
+	e, _ := f.call(false, Call{Method: "QuoteName", Subject: name})
+	if quoted := valueOf[string](e); quoted != "" {
+		return quoted
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func (f *Fake) Close() error {
+	_, err := f.call(false, Call{Method: "Close"})
+	return err
+}
+
+func (f *Fake) page(call Call) (sqlite.Page, error) {
+	e, err := f.call(true, call)
+	if err != nil {
+		return sqlite.Page{}, err
+	}
+	if page, ok := e.value.(sqlite.Page); ok {
+		return page, nil
+	}
+	return sqlite.Page{Rows: e.rows}, nil
+}
@@ -0,0 +1,239 @@
+package sqlitefake
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/halushko/core-go/sqlite"
+)
+
+var ErrUnexpected = errors.New("unexpected call")
+
+type Call struct {
+	Method        string
+	Subject       string
+	Args          []any
+	Params        map[string]any
+	InTransaction bool
+}
+
+type Expectation struct {
+	method   string
+	pattern  *regexp.Regexp
+	rows     []map[string]any
+	value    any
+	err      error
+	times    int
+	optional bool
+	calls    int
+}
+
+// Queries, statements and files fail unless an expectation matches them, everything else succeeds with zero values
+type Fake struct {
+	mutex        sync.Mutex
+	calls        []Call
+	expectations []*Expectation
+	unexpected   []Call
+}
+
+var (
+	_ sqlite.DBI            = (*Fake)(nil)
+	_ sqlite.QueryDBI       = (*Fake)(nil)
+	_ sqlite.InterceptorDBI = (*Fake)(nil)
+	_ sqlite.DataDBI        = (*Fake)(nil)
+	_ sqlite.SchemaDBI      = (*Fake)(nil)
+	_ sqlite.SoftDeleteDBI  = (*Fake)(nil)
+	_ sqlite.HistoryDBI     = (*Fake)(nil)
+	_ sqlite.SpatialDBI     = (*Fake)(nil)
+	_ sqlite.CacheDBI       = (*Fake)(nil)
+	_ sqlite.MaintenanceDBI = (*Fake)(nil)
+	_ sqlite.AttachDBI      = (*Fake)(nil)
+)
+
+func New() *Fake {
+	return &Fake{}
+}
+
+// The method matches by prefix, so "ExecSelect" covers every ExecSelect variant and "" covers all methods.
+// The pattern is matched against the query, the file path or the table name of the call.
+func (f *Fake) Expect(method string, pattern string) *Expectation {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	e := &Expectation{method: method, pattern: regexp.MustCompile(pattern)}
+	f.expectations = append(f.expectations, e)
+	return e
+}
+
+func (f *Fake) ExpectSelect(pattern string) *Expectation {
+	return f.Expect("ExecSelect", pattern)
+}
+
+func (f *Fake) ExpectExecute(pattern string) *Expectation {
+	return f.Expect("Execute", pattern)
+}
+
+func (e *Expectation) Return(rows ...map[string]any) *Expectation {
+	e.rows = rows
+	return e
+}
+
+// For methods that return something other than rows, such as Page, []Column or the exported text
+func (e *Expectation) ReturnValue(value any) *Expectation {
+	e.value = value
+	return e
+}
+
+func (e *Expectation) ReturnError(err error) *Expectation {
+	e.err = err
+	return e
+}
+
+// Stops matching after n calls and must be called exactly n times, 0 matches any number of calls
+func (e *Expectation) Times(n int) *Expectation {
+	e.times = n
+	return e
+}
+
+func (e *Expectation) Maybe() *Expectation {
+	e.optional = true
+	return e
+}
+
+func (e *Expectation) String() string {
+	method := e.method
+	if method == "" {
+		method = "any method"
+	}
+	return fmt.Sprintf("%s matching %q", method, e.pattern)
+}
+
+func (f *Fake) Calls() []Call {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return append([]Call(nil), f.calls...)
+}
+
+func (f *Fake) CallsTo(method string) []Call {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	var calls []Call
+	for _, call := range f.calls {
+		if strings.HasPrefix(call.Method, method) {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+func (f *Fake) Reset() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.calls = nil
+	f.expectations = nil
+	f.unexpected = nil
+}
+
+func (f *Fake) ExpectationsMet() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	var errs []error
+	for _, e := range f.expectations {
+		switch {
+		case e.times > 0 && e.calls != e.times:
+			errs = append(errs, fmt.Errorf("%s: called %d times, want %d", e, e.calls, e.times))
+		case e.times == 0 && e.calls == 0 && !e.optional:
+			errs = append(errs, fmt.Errorf("%s: never called", e))
+		}
+	}
+	for _, call := range f.unexpected {
+		errs = append(errs, fmt.Errorf("%w: %s %q", ErrUnexpected, call.Method, call.Subject))
+	}
+	return errors.Join(errs...)
+}
+
+func Verify(t testing.TB, f *Fake) {
+	t.Helper()
+
+	if err := f.ExpectationsMet(); err != nil {
+		t.Errorf("sqlite fake expectations: %v", err)
+	}
+}
+
+func (f *Fake) call(strict bool, call Call) (*Expectation, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.calls = append(f.calls, call)
+	for _, e := range f.expectations {
+		if !strings.HasPrefix(call.Method, e.method) || !e.pattern.MatchString(call.Subject) {
+			continue
+		}
+		if e.times > 0 && e.calls >= e.times {
+			continue
+		}
+		e.calls++
+		return e, e.err
+	}
+
+	if !strict {
+		return nil, nil
+	}
+	f.unexpected = append(f.unexpected, call)
+	return nil, fmt.Errorf("%w: %s %q", ErrUnexpected, call.Method, call.Subject)
+}
+
+func (f *Fake) rows(call Call) ([]map[string]any, error) {
+	e, err := f.call(true, call)
+	if err != nil {
+		return nil, err
+	}
+	return e.rows, nil
+}
+
+// A Result given with ReturnValue keeps its column order, rows are ordered by column name
+func (f *Fake) result(call Call) (sqlite.Result, error) {
+	e, err := f.call(true, call)
+	if err != nil {
+		return sqlite.Result{}, err
+	}
+	if result, ok := e.value.(sqlite.Result); ok {
+		return result, nil
+	}
+	return sqlite.NewResult(e.rows), nil
+}
+
+func valueOf[T any](e *Expectation) T {
+	var zero T
+	if e == nil {
+		return zero
+	}
+	if value, ok := e.value.(T); ok {
+		return value
+	}
+	return zero
+}
+
+func write(w io.Writer, e *Expectation) error {
+	if e == nil || w == nil {
+		return nil
+	}
+	switch value := e.value.(type) {
+	case string:
+		_, err := io.WriteString(w, value)
+		return err
+	case []byte:
+		_, err := w.Write(value)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package sqlitefake_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/halushko/core-go/sqlite/sqlitefake"
+)
+
+func TestExpectationMatching(t *testing.T) {
+	f := sqlitefake.New()
+	f.ExpectSelect(`FROM users`).Return(map[string]any{"id": 1})
+	f.ExpectExecute(`^DELETE`).ReturnError(errors.New("locked"))
+
+	rows, err := f.ExecSelectWithTimeoutNamed("SELECT id FROM users WHERE id = :id", time.Second, map[string]any{"id": 1})
+	if err != nil || len(rows) != 1 || rows[0]["id"] != 1 {
+		t.Fatalf("select: rows %v, err %v", rows, err)
+	}
+	if err := f.Execute("DELETE FROM users"); err == nil || err.Error() != "locked" {
+		t.Fatalf("delete: err %v, want locked", err)
+	}
+	if _, err := f.ExecSelect("SELECT * FROM orders"); !errors.Is(err, sqlitefake.ErrUnexpected) {
+		t.Fatalf("unmatched select: err %v, want ErrUnexpected", err)
+	}
+	if err := f.TruncateTable("orders"); err != nil {
+		t.Fatalf("truncate without expectation: %v", err)
+	}
+
+	if calls := f.CallsTo("ExecSelect"); len(calls) != 2 || calls[0].Method != "ExecSelectWithTimeoutNamed" {
+		t.Fatalf("ExecSelect calls: %+v", calls)
+	}
+	if err := f.ExpectationsMet(); !errors.Is(err, sqlitefake.ErrUnexpected) {
+		t.Fatalf("expectations: %v, want the unexpected select", err)
+	}
+}
+
+func TestExpectationTimesAndMaybe(t *testing.T) {
+	f := sqlitefake.New()
+	f.ExpectSelect(`FROM users`).Times(2).Return(map[string]any{"id": 1})
+	f.ExpectSelect(`FROM users`).Return(map[string]any{"id": 2})
+	f.ExpectExecute(`VACUUM`).Maybe()
+
+	var got []any
+	for range 3 {
+		rows, err := f.ExecSelect("SELECT id FROM users")
+		if err != nil {
+			t.Fatalf("select: %v", err)
+		}
+		got = append(got, rows[0]["id"])
+	}
+	if got[0] != 1 || got[1] != 1 || got[2] != 2 {
+		t.Fatalf("ids %v, want [1 1 2]", got)
+	}
+	if err := f.ExpectationsMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestExpectationsMet(t *testing.T) {
+	f := sqlitefake.New()
+	f.ExpectSelect(`FROM users`).Times(2)
+	f.ExpectExecute(`INSERT`)
+
+	if _, err := f.ExecSelect("SELECT id FROM users"); err != nil {
+		t.Fatalf("select: %v", err)
+	}
+
+	err := f.ExpectationsMet()
+	if err == nil {
+		t.Fatal("expectations met, want the missing calls reported")
+	}
+	for _, want := range []string{"called 1 times, want 2", "never called"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expectations: %v, want %q", err, want)
+		}
+	}
+
+	f.Reset()
+	if err := f.ExpectationsMet(); err != nil {
+		t.Fatalf("after reset: %v", err)
+	}
+}
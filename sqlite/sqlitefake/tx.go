@@ -0,0 +1,53 @@
+package sqlitefake
+
+import (
+	"errors"
+
+	"github.com/halushko/core-go/sqlite"
+)
+
+var _ sqlite.TxDBI = (*Tx)(nil)
+
+// Calls are matched by the same expectations as the ones on the fake, with InTransaction set
+type Tx struct {
+	fake *Fake
+}
+
+// For code under test that takes a sqlite.TxDBI directly
+func (f *Fake) Tx() *Tx {
+	return &Tx{fake: f}
+}
+
+func (tx *Tx) Execute(query string, args ...any) error {
+	_, err := tx.fake.call(true, Call{Method: "Execute", Subject: query, Args: args, InTransaction: true})
+	return err
+}
+
+func (tx *Tx) ExecuteNamed(query string, params map[string]any) error {
+	_, err := tx.fake.call(true, Call{Method: "ExecuteNamed", Subject: query, Params: params, InTransaction: true})
+	return err
+}
+
+func (tx *Tx) ExecSelect(query string, args ...any) ([]map[string]any, error) {
+	return tx.fake.rows(Call{Method: "ExecSelect", Subject: query, Args: args, InTransaction: true})
+}
+
+func (tx *Tx) ExecSelectNamed(query string, params map[string]any) ([]map[string]any, error) {
+	return tx.fake.rows(Call{Method: "ExecSelectNamed", Subject: query, Params: params, InTransaction: true})
+}
+
+func (tx *Tx) ExecSelectResult(query string, args ...any) (sqlite.Result, error) {
+	return tx.fake.result(Call{Method: "ExecSelectResult", Subject: query, Args: args, InTransaction: true})
+}
+
+// The function always runs against the fake transaction unless an expectation on the transaction
+// returns an error, which stands for a failed BEGIN. Writes are not rolled back when fn fails
+func (f *Fake) transaction(call Call, fn func(tx sqlite.TxDBI) error) error {
+	if fn == nil {
+		return errors.New("transaction function is nil")
+	}
+	if _, err := f.call(false, call); err != nil {
+		return err
+	}
+	return fn(f.Tx())
+}
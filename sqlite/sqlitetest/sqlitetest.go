@@ -0,0 +1,197 @@
+package sqlitetest
+
+import (
+	"fmt"
+	"io/fs"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/halushko/core-go/sqlite"
+)
+
+var (
+	counter        atomic.Uint64
+	unsafeNameChar = regexp.MustCompile(`[^a-zA-Z0-9_]+`)
+)
+
+func New(t testing.TB, tables ...sqlite.Table) *sqlite.Client {
+	t.Helper()
+
+	name := fmt.Sprintf("%s_%d", unsafeNameChar.ReplaceAllString(t.Name(), "_"), counter.Add(1))
+	client, err := sqlite.OpenMemory(name)
+	if err != nil {
+		t.Fatalf("open memory db %q: %v", name, err)
+	}
+	t.Cleanup(func() {
+		if err := client.Close(); err != nil {
+			t.Errorf("close memory db %q: %v", name, err)
+		}
+	})
+
+	for _, table := range tables {
+		if err := client.CreateTable(table); err != nil {
+			t.Fatalf("create table %q: %v", table.Name, err)
+		}
+	}
+
+	return client
+}
+
+func Migrate(t testing.TB, client *sqlite.Client, paths ...string) {
+	t.Helper()
+
+	for _, path := range paths {
+		if err := client.ExecuteSqlFile(path); err != nil {
+			t.Fatalf("apply migration %q: %v", path, err)
+		}
+	}
+}
+
+func Fixtures(t testing.TB, client *sqlite.Client, fsys fs.FS, files ...string) {
+	t.Helper()
+
+	if err := client.LoadFixtures(fsys, files...); err != nil {
+		t.Fatalf("load fixtures %v: %v", files, err)
+	}
+}
+
+func Exec(t testing.TB, client *sqlite.Client, query string, args ...any) {
+	t.Helper()
+
+	if err := client.Execute(query, args...); err != nil {
+		t.Fatalf("execute %q: %v", query, err)
+	}
+}
+
+func Seed(t testing.TB, client *sqlite.Client, table string, rows ...map[string]any) {
+	t.Helper()
+
+	for i, row := range rows {
+		if len(row) == 0 {
+			continue
+		}
+
+		columns := sortedKeys(row)
+		names := make([]string, 0, len(columns))
+		holders := make([]string, 0, len(columns))
+		args := make([]any, 0, len(columns))
+		for _, column := range columns {
+			names = append(names, quote(column))
+			holders = append(holders, "?")
+			args = append(args, row[column])
+		}
+
+		query := fmt.Sprintf(
+			"INSERT INTO %s (%s) VALUES (%s)",
+			client.QuoteName(table),
+			strings.Join(names, ", "),
+			strings.Join(holders, ", "),
+		)
+		if err := client.Execute(query, args...); err != nil {
+			t.Fatalf("seed %q row %d: %v", table, i, err)
+		}
+	}
+}
+
+func AssertRowCount(t testing.TB, client *sqlite.Client, table string, want int) {
+	t.Helper()
+
+	rows, err := client.ExecSelect(fmt.Sprintf("SELECT COUNT(*) AS n FROM %s", client.QuoteName(table)))
+	if err != nil {
+		t.Fatalf("count rows in %q: %v", table, err)
+	}
+
+	if got := normalize(rows[0]["n"]); got != int64(want) {
+		t.Errorf("table %q: got %v rows, want %d", table, got, want)
+	}
+}
+
+func AssertRows(t testing.TB, client *sqlite.Client, want []map[string]any, query string, args ...any) {
+	t.Helper()
+
+	got, err := client.ExecSelect(query, args...)
+	if err != nil {
+		t.Fatalf("select %q: %v", query, err)
+	}
+
+	if len(got) != len(want) {
+		t.Errorf("query %q: got %d rows, want %d\ngot:  %v\nwant: %v", query, len(got), len(want), got, want)
+		return
+	}
+
+	for i := range want {
+		for column, wantValue := range want[i] {
+			gotValue, ok := got[i][column]
+			if !ok {
+				t.Errorf("query %q row %d: column %q is missing", query, i, column)
+				continue
+			}
+			if !reflect.DeepEqual(normalize(gotValue), normalize(wantValue)) {
+				t.Errorf("query %q row %d column %q: got %v (%T), want %v (%T)",
+					query, i, column, gotValue, gotValue, wantValue, wantValue)
+			}
+		}
+	}
+}
+
+func AssertTableRows(t testing.TB, client *sqlite.Client, table string, orderBy string, want ...map[string]any) {
+	t.Helper()
+
+	query := fmt.Sprintf("SELECT * FROM %s", client.QuoteName(table))
+	if orderBy != "" {
+		query += " ORDER BY " + orderBy
+	}
+
+	AssertRows(t, client, want, query)
+}
+
+func normalize(v any) any {
+	switch value := v.(type) {
+	case int:
+		return int64(value)
+	case int8:
+		return int64(value)
+	case int16:
+		return int64(value)
+	case int32:
+		return int64(value)
+	case uint:
+		return int64(value)
+	case uint8:
+		return int64(value)
+	case uint16:
+		return int64(value)
+	case uint32:
+		return int64(value)
+	case uint64:
+		return int64(value)
+	case float32:
+		return float64(value)
+	case bool:
+		if value {
+			return int64(1)
+		}
+		return int64(0)
+	case []byte:
+		return string(value)
+	default:
+		return v
+	}
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func quote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
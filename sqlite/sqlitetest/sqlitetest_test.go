@@ -0,0 +1,146 @@
+package sqlitetest_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/halushko/core-go/sqlite"
+	"github.com/halushko/core-go/sqlite/sqlitetest"
+)
+
+var users = sqlite.Table{
+	Name: "users",
+	Columns: []sqlite.Column{
+		{Name: "id", Type: sqlite.TypeInteger, PrimaryKey: boolPtr(true)},
+		{Name: "name", Type: sqlite.TypeText},
+		{Name: "active", Type: sqlite.TypeInteger},
+	},
+}
+
+func boolPtr(v bool) *bool {
+	return &v
+}
+
+// Collects the failures of a helper instead of failing the test running it
+type recorder struct {
+	testing.TB
+	failures []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Fatalf(format string, args ...any) {
+	r.Errorf(format, args...)
+	runtime.Goexit()
+}
+
+func failures(t *testing.T, fn func(tb testing.TB)) []string {
+	r := &recorder{TB: t}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn(r)
+	}()
+	<-done
+	return r.failures
+}
+
+func TestSeedAndAssert(t *testing.T) {
+	client := sqlitetest.New(t, users)
+
+	sqlitetest.Seed(t, client, "main.users",
+		map[string]any{"id": 2, "name": "bob", "active": false},
+		map[string]any{"id": 1, "name": "ann", "active": true},
+	)
+
+	sqlitetest.AssertRowCount(t, client, "users", 2)
+	sqlitetest.AssertTableRows(t, client, "main.users", "id",
+		map[string]any{"id": 1, "name": "ann", "active": true},
+		map[string]any{"id": 2, "name": []byte("bob"), "active": 0},
+	)
+	sqlitetest.AssertRows(t, client, []map[string]any{{"n": uint8(1)}}, "SELECT COUNT(*) AS n FROM users WHERE active = ?", 1)
+}
+
+func TestAssertionsReportMismatches(t *testing.T) {
+	client := sqlitetest.New(t, users)
+	sqlitetest.Seed(t, client, "users", map[string]any{"id": 1, "name": "ann"})
+
+	for name, tc := range map[string]struct {
+		assert func(tb testing.TB)
+		want   string
+	}{
+		"row count": {
+			assert: func(tb testing.TB) { sqlitetest.AssertRowCount(tb, client, "users", 2) },
+			want:   "got 1 rows, want 2",
+		},
+		"value": {
+			assert: func(tb testing.TB) {
+				sqlitetest.AssertTableRows(tb, client, "users", "", map[string]any{"name": "bob"})
+			},
+			want: `column "name": got ann`,
+		},
+		"missing column": {
+			assert: func(tb testing.TB) {
+				sqlitetest.AssertRows(tb, client, []map[string]any{{"email": "a@b"}}, "SELECT name FROM users")
+			},
+			want: `column "email" is missing`,
+		},
+		"rows": {
+			assert: func(tb testing.TB) { sqlitetest.AssertRows(tb, client, nil, "SELECT * FROM users") },
+			want:   "got 1 rows, want 0",
+		},
+		"failed statement": {
+			assert: func(tb testing.TB) { sqlitetest.Exec(tb, client, "INSERT INTO missing VALUES (1)") },
+			want:   "no such table",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			got := failures(t, tc.assert)
+			if len(got) != 1 || !strings.Contains(got[0], tc.want) {
+				t.Errorf("failures %q, want one containing %q", got, tc.want)
+			}
+		})
+	}
+}
+
+// Names are qualified the way the client does it, a dot alone does not make a schema
+func TestQualifiedTableNames(t *testing.T) {
+	t.Setenv("DB_PATH", t.TempDir())
+	client := sqlitetest.New(t)
+	if err := client.Attach("aux", "other", false); err != nil {
+		t.Fatalf("attach: %v", err)
+	}
+
+	sqlitetest.Exec(t, client, `CREATE TABLE "my.table" (id INTEGER)`)
+	sqlitetest.Exec(t, client, "CREATE TABLE aux.notes (id INTEGER)")
+
+	for _, table := range []string{"my.table", "aux.notes"} {
+		sqlitetest.Seed(t, client, table, map[string]any{"id": 1})
+		sqlitetest.AssertRowCount(t, client, table, 1)
+		sqlitetest.AssertTableRows(t, client, table, "", map[string]any{"id": 1})
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	dir := t.TempDir()
+	for name, script := range map[string]string{
+		"001.sql": "CREATE TABLE notes (id INTEGER PRIMARY KEY, body TEXT);",
+		"002.sql": "INSERT INTO notes (id, body) VALUES (1, 'a');",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0o600); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	client := sqlitetest.New(t)
+	sqlitetest.Migrate(t, client, filepath.Join(dir, "001.sql"), filepath.Join(dir, "002.sql"))
+	sqlitetest.AssertTableRows(t, client, "notes", "", map[string]any{"id": 1, "body": "a"})
+}
@@ -4,15 +4,41 @@ import (
 	external "database/sql"
 	"regexp"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const dbDefaultPath = "/data/sqlite"
 
+const (
+	ColumnCreatedAt = "created_at"
+	ColumnUpdatedAt = "updated_at"
+	ColumnDeletedAt = "deleted_at"
+
+	ColumnHistoryID    = "history_id"
+	ColumnValidFrom    = "valid_from"
+	ColumnValidTo      = "valid_to"
+	HistoryTableSuffix = "_history"
+)
+
 var macroRegexp = regexp.MustCompile(`#\$([a-zA-Z_][a-zA-Z0-9_]*)\$#`)
 
 type Client struct {
-	db    *external.DB
-	mutex *sync.Mutex
+	db     *external.DB
+	conn   *connector
+	keeper *external.Conn
+	mutex  *sync.Mutex
+
+	maintenanceMutex sync.Mutex
+	maintenance      *maintenance
+
+	scanThreshold atomic.Int64
+
+	retentionMutex sync.Mutex
+	retention      map[string]retentionRule
+
+	interceptorsMutex sync.RWMutex
+	interceptors      []Interceptor
 }
 
 type ColumnType string
@@ -25,9 +51,26 @@ const (
 	TypeDatetime ColumnType = "DATETIME"
 )
 
+type ConflictResolution string
+
+const (
+	ConflictRollback ConflictResolution = "ROLLBACK"
+	ConflictAbort    ConflictResolution = "ABORT"
+	ConflictFail     ConflictResolution = "FAIL"
+	ConflictIgnore   ConflictResolution = "IGNORE"
+	ConflictReplace  ConflictResolution = "REPLACE"
+)
+
+type PrimaryKey struct {
+	Name       string
+	Columns    []string
+	OnConflict ConflictResolution
+}
+
 type UniqueConstraint struct {
-	Name    string
-	Columns []string
+	Name       string
+	Columns    []string
+	OnConflict ConflictResolution
 }
 
 type CheckConstraint struct {
@@ -46,29 +89,98 @@ type ForeignKey struct {
 	InitiallyDeferred *bool
 }
 
+type IndexKey struct {
+	Column  string
+	Expr    string
+	Collate string
+	Desc    bool
+}
+
 type Index struct {
 	Name    string
 	Unique  bool
 	Columns []string
+	Keys    []IndexKey
 	Where   string
 }
 
+type IndexInfo struct {
+	Name       string
+	Table      string
+	Unique     bool
+	Partial    bool
+	Origin     string
+	Columns    []string
+	SQL        string
+	Stat       string
+	Rows       int64
+	RowsPerKey []int64
+	Hint       string
+}
+
 type Column struct {
-	Name          string
-	Type          ColumnType
-	PrimaryKey    *bool
-	NotNull       *bool
-	Unique        *bool
-	GeneratedExpr *string
-	Stored        *bool
-	Default       *string
+	Name                 string
+	Type                 ColumnType
+	PrimaryKey           *bool
+	PrimaryKeyOnConflict ConflictResolution
+	AutoIncrement        *bool
+	NotNull              *bool
+	NotNullOnConflict    ConflictResolution
+	Unique               *bool
+	UniqueOnConflict     ConflictResolution
+	GeneratedExpr        *string
+	Stored               *bool
+	Default              *string
 }
 
 type Table struct {
 	Name              string
 	Columns           []Column
+	PrimaryKey        *PrimaryKey
 	UniqueConstraints []UniqueConstraint
 	Checks            []CheckConstraint
 	ForeignKeys       []ForeignKey
 	Indexes           []Index
+	Timestamps        *bool
+	SoftDelete        *bool
+	Retention         *Retention
+	History           *History
+}
+
+type Retention struct {
+	// Text timestamps, or unix seconds in an INTEGER column
+	Column    string
+	MaxAge    time.Duration
+	MaxRows   int64
+	BatchSize int
+}
+
+// Old versions are pruned once they were replaced longer than Retention ago, 0 keeps them forever
+type History struct {
+	Retention time.Duration
+}
+
+type View struct {
+	Name  string
+	Query string
+}
+
+type Trigger struct {
+	Name    string
+	Table   string
+	Timing  string
+	Event   string
+	Columns []string
+	When    string
+	Body    string
+}
+
+type Schema struct {
+	Tables   []Table
+	Views    []View
+	Triggers []Trigger
+}
+
+type SQLOptions struct {
+	IfNotExists bool
 }
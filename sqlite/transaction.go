@@ -0,0 +1,167 @@
+package sqlite
+
+import (
+	"context"
+	external "database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const defaultTransactionTimeout = 30 * time.Second
+
+var _ TxDBI = (*Tx)(nil)
+
+// What a transaction function can run, satisfied by *Tx and by fakes
+type TxDBI interface {
+	Execute(query string, args ...any) error
+	ExecuteNamed(query string, params map[string]any) error
+	ExecSelect(query string, args ...any) ([]map[string]any, error)
+	ExecSelectNamed(query string, params map[string]any) ([]map[string]any, error)
+	ExecSelectResult(query string, args ...any) (Result, error)
+}
+
+type Tx struct {
+	tx     *external.Tx
+	ctx    context.Context
+	client *Client
+}
+
+func (c *Client) Transaction(fn func(tx TxDBI) error) error {
+	return c.TransactionWithTimeout(defaultTransactionTimeout, fn)
+}
+
+func (c *Client) TransactionWithTimeout(timeout time.Duration, fn func(tx TxDBI) error) error {
+	if fn == nil {
+		return errors.New("transaction function is nil")
+	}
+	return c.transactionWithTimeout(timeout, func(tx *Tx) error {
+		return fn(tx)
+	})
+}
+
+func (c *Client) transactionWithTimeout(timeout time.Duration, fn func(tx *Tx) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return c.transaction(ctx, fn)
+}
+
+func (c *Client) transaction(ctx context.Context, fn func(tx *Tx) error) error {
+	if c == nil || c.db == nil {
+		return errors.New("db client is nil")
+	}
+	if fn == nil {
+		return errors.New("transaction function is nil")
+	}
+
+	return c.intercept(ctx, &Query{Kind: QueryTransaction}, func(ctx context.Context, q *Query) error {
+		return c.runTransaction(ctx, fn)
+	})
+}
+
+func (c *Client) runTransaction(ctx context.Context, fn func(tx *Tx) error) (err error) {
+	if c.mutex != nil {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+	}
+
+	sqlTx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = sqlTx.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			if rbErr := sqlTx.Rollback(); rbErr != nil && !errors.Is(rbErr, external.ErrTxDone) {
+				err = errors.Join(err, fmt.Errorf("rollback: %w", rbErr))
+			}
+		}
+	}()
+
+	if err = fn(&Tx{tx: sqlTx, ctx: ctx, client: c}); err != nil {
+		return err
+	}
+
+	if err = sqlTx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	return nil
+}
+
+func (tx *Tx) Execute(query string, args ...any) error {
+	_, err := tx.exec(query, args...)
+	return err
+}
+
+func (tx *Tx) ExecuteNamed(query string, params map[string]any) error {
+	compiledQuery, args, err := buildMacrosQuery(query, params)
+	if err != nil {
+		return fmt.Errorf("compile named query: %w", err)
+	}
+
+	return tx.Execute(compiledQuery, args...)
+}
+
+func (tx *Tx) ExecSelect(query string, args ...any) ([]map[string]any, error) {
+	if tx == nil || tx.tx == nil {
+		return nil, errors.New("transaction is nil")
+	}
+
+	var out []map[string]any
+	err := tx.intercept(&Query{Kind: QuerySelect, SQL: query, Args: args, InTransaction: true}, func(ctx context.Context, q *Query) error {
+		rows, err := tx.tx.QueryContext(ctx, q.SQL, q.Args...)
+		if err != nil {
+			return fmt.Errorf("select query: %w", err)
+		}
+		defer rows.Close()
+
+		out, err = scanRows(rows)
+		q.Rows = int64(len(out))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (tx *Tx) ExecSelectNamed(query string, params map[string]any) ([]map[string]any, error) {
+	compiledQuery, args, err := buildMacrosQuery(query, params)
+	if err != nil {
+		return nil, fmt.Errorf("compile named query: %w", err)
+	}
+	return tx.ExecSelect(compiledQuery, args...)
+}
+
+func (tx *Tx) exec(query string, args ...any) (external.Result, error) {
+	if tx == nil || tx.tx == nil {
+		return nil, errors.New("transaction is nil")
+	}
+
+	var result external.Result
+	err := tx.intercept(&Query{Kind: QueryExecute, SQL: query, Args: args, InTransaction: true}, func(ctx context.Context, q *Query) error {
+		var err error
+		if result, err = tx.tx.ExecContext(ctx, q.SQL, q.Args...); err != nil {
+			return fmt.Errorf("execute: %w", err)
+		}
+		q.Rows, _ = result.RowsAffected()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// A Tx built outside Client.transaction has no client and skips the interceptors
+func (tx *Tx) intercept(q *Query, run QueryHandler) error {
+	if tx.client == nil {
+		return run(tx.ctx, q)
+	}
+	return tx.client.intercept(tx.ctx, q, run)
+}
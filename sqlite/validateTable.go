@@ -0,0 +1,297 @@
+package sqlite
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+func (t Table) Validate() error {
+	var errs []error
+	if strings.TrimSpace(t.Name) == "" {
+		errs = append(errs, errors.New("table name is empty"))
+	}
+	if len(t.Columns) == 0 {
+		errs = append(errs, fmt.Errorf("table %q has no columns", t.Name))
+	}
+
+	t = withAuditColumns(t)
+	columns := make(map[string]bool, len(t.Columns))
+	primaryKeys := 0
+
+	for i, c := range t.Columns {
+		if strings.TrimSpace(c.Name) == "" {
+			errs = append(errs, fmt.Errorf("column #%d name is empty", i+1))
+			continue
+		}
+		key := strings.ToLower(c.Name)
+		if columns[key] {
+			errs = append(errs, fmt.Errorf("duplicate column %q", c.Name))
+		}
+		columns[key] = true
+
+		if err := c.Type.validateColumnType(); err != nil {
+			errs = append(errs, fmt.Errorf("column %q: %w", c.Name, err))
+		}
+
+		isPrimaryKey := c.PrimaryKey != nil && *c.PrimaryKey
+		if isPrimaryKey {
+			primaryKeys++
+		}
+		if c.AutoIncrement != nil && *c.AutoIncrement && (!isPrimaryKey || c.Type != TypeInteger) {
+			errs = append(errs, fmt.Errorf("column %q: AUTOINCREMENT needs an INTEGER PRIMARY KEY column", c.Name))
+		}
+		for _, conflict := range []ConflictResolution{c.PrimaryKeyOnConflict, c.NotNullOnConflict, c.UniqueOnConflict} {
+			if err := conflict.validate(); err != nil {
+				errs = append(errs, fmt.Errorf("column %q: %w", c.Name, err))
+			}
+		}
+
+		if c.GeneratedExpr != nil {
+			if strings.TrimSpace(*c.GeneratedExpr) == "" {
+				errs = append(errs, fmt.Errorf("generated column %q has an empty expression", c.Name))
+			}
+			if c.Default != nil {
+				errs = append(errs, fmt.Errorf("generated column %q cannot have a default", c.Name))
+			}
+			if isPrimaryKey {
+				errs = append(errs, fmt.Errorf("generated column %q cannot be a primary key", c.Name))
+			}
+		}
+	}
+
+	if primaryKeys > 1 {
+		errs = append(errs, fmt.Errorf("table %q has %d primary key columns, want at most one", t.Name, primaryKeys))
+	}
+	if t.PrimaryKey != nil {
+		if primaryKeys > 0 {
+			errs = append(errs, fmt.Errorf("table %q declares both column and table primary keys", t.Name))
+		}
+		if err := t.PrimaryKey.OnConflict.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("primary key: %w", err))
+		}
+	}
+
+	checkColumns := func(owner string, names []string) {
+		if len(names) == 0 {
+			errs = append(errs, fmt.Errorf("%s has no columns", owner))
+		}
+		for _, name := range names {
+			if !columns[strings.ToLower(name)] {
+				errs = append(errs, fmt.Errorf("%s references unknown column %q", owner, name))
+			}
+		}
+	}
+
+	if t.PrimaryKey != nil {
+		checkColumns(constraintLabel("primary key", t.PrimaryKey.Name, 0), t.PrimaryKey.Columns)
+	}
+
+	for i, uc := range t.UniqueConstraints {
+		label := constraintLabel("unique constraint", uc.Name, i)
+		checkColumns(label, uc.Columns)
+		if err := uc.OnConflict.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", label, err))
+		}
+	}
+
+	for i, chk := range t.Checks {
+		if strings.TrimSpace(chk.Expr) == "" {
+			errs = append(errs, fmt.Errorf("%s has an empty expression", constraintLabel("check", chk.Name, i)))
+		}
+	}
+
+	for i, fk := range t.ForeignKeys {
+		label := constraintLabel("foreign key", fk.Name, i)
+		checkColumns(label, fk.Columns)
+		if strings.TrimSpace(fk.ReferenceTable) == "" {
+			errs = append(errs, fmt.Errorf("%s reference table is empty", label))
+		}
+		if len(fk.ReferenceColumns) == 0 {
+			errs = append(errs, fmt.Errorf("%s has no reference columns", label))
+		} else if len(fk.ReferenceColumns) != len(fk.Columns) {
+			errs = append(errs, fmt.Errorf("%s has %d columns and %d reference columns", label, len(fk.Columns), len(fk.ReferenceColumns)))
+		}
+		for _, ref := range fk.ReferenceColumns {
+			if strings.TrimSpace(ref) == "" {
+				errs = append(errs, fmt.Errorf("%s reference column name is empty", label))
+			}
+		}
+		if err := validateForeignKeyAction(fk.OnDelete); err != nil {
+			errs = append(errs, fmt.Errorf("%s ON DELETE: %w", label, err))
+		}
+		if err := validateForeignKeyAction(fk.OnUpdate); err != nil {
+			errs = append(errs, fmt.Errorf("%s ON UPDATE: %w", label, err))
+		}
+	}
+
+	indexes := make(map[string]bool, len(t.Indexes))
+	for i, idx := range t.Indexes {
+		if strings.TrimSpace(idx.Name) == "" {
+			errs = append(errs, fmt.Errorf("index #%d name is empty", i+1))
+		} else {
+			key := strings.ToLower(idx.Name)
+			if indexes[key] {
+				errs = append(errs, fmt.Errorf("duplicate index %q", idx.Name))
+			}
+			indexes[key] = true
+		}
+		label := constraintLabel("index", idx.Name, i)
+		if len(idx.Keys) == 0 {
+			checkColumns(label, idx.Columns)
+			continue
+		}
+		if len(idx.Columns) > 0 {
+			errs = append(errs, fmt.Errorf("%s sets both columns and keys", label))
+		}
+		for j, key := range idx.Keys {
+			hasColumn := strings.TrimSpace(key.Column) != ""
+			hasExpr := strings.TrimSpace(key.Expr) != ""
+			switch {
+			case hasColumn == hasExpr:
+				errs = append(errs, fmt.Errorf("%s key #%d needs exactly one of column or expression", label, j+1))
+			case hasColumn && !columns[strings.ToLower(key.Column)]:
+				errs = append(errs, fmt.Errorf("%s references unknown column %q", label, key.Column))
+			}
+			if key.Collate != "" && !identifierRegexp.MatchString(key.Collate) {
+				errs = append(errs, fmt.Errorf("%s key #%d has invalid collation %q", label, j+1, key.Collate))
+			}
+		}
+	}
+
+	if t.Retention != nil {
+		if err := t.Retention.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("retention: %w", err))
+		}
+		if t.Retention.Column != "" && !columns[strings.ToLower(t.Retention.Column)] {
+			errs = append(errs, fmt.Errorf("retention references unknown column %q", t.Retention.Column))
+		}
+	}
+
+	if t.History != nil {
+		if t.History.Retention < 0 {
+			errs = append(errs, fmt.Errorf("history: invalid retention: %s", t.History.Retention))
+		}
+		if len(primaryKeyColumns(t)) == 0 {
+			errs = append(errs, errors.New("history needs a primary key"))
+		}
+		for _, name := range []string{ColumnHistoryID, ColumnValidFrom, ColumnValidTo} {
+			if columns[name] {
+				errs = append(errs, fmt.Errorf("history column %q clashes with a table column", name))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (s Schema) Validate() error {
+	var errs []error
+	names := map[string]bool{}
+	unique := func(kind string, name string) {
+		key := strings.ToLower(name)
+		if names[key] {
+			errs = append(errs, fmt.Errorf("duplicate %s %q", kind, name))
+		}
+		names[key] = true
+	}
+
+	for i, t := range s.Tables {
+		if err := t.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", constraintLabel("table", t.Name, i), err))
+		}
+		if t.Name != "" {
+			unique("table", t.Name)
+		}
+	}
+
+	for i, v := range s.Views {
+		label := constraintLabel("view", v.Name, i)
+		if strings.TrimSpace(v.Name) == "" {
+			errs = append(errs, fmt.Errorf("view #%d name is empty", i+1))
+		} else {
+			unique("view", v.Name)
+		}
+		if strings.TrimSpace(v.Query) == "" {
+			errs = append(errs, fmt.Errorf("%s has an empty query", label))
+		}
+	}
+
+	triggers := map[string]bool{}
+	for i, tr := range s.Triggers {
+		label := constraintLabel("trigger", tr.Name, i)
+		if strings.TrimSpace(tr.Name) == "" {
+			errs = append(errs, fmt.Errorf("trigger #%d name is empty", i+1))
+		} else if key := strings.ToLower(tr.Name); triggers[key] {
+			errs = append(errs, fmt.Errorf("duplicate trigger %q", tr.Name))
+		} else {
+			triggers[key] = true
+		}
+		if strings.TrimSpace(tr.Table) == "" {
+			errs = append(errs, fmt.Errorf("%s table is empty", label))
+		}
+		switch strings.ToUpper(strings.TrimSpace(tr.Timing)) {
+		case "", "BEFORE", "AFTER", "INSTEAD OF":
+		default:
+			errs = append(errs, fmt.Errorf("%s has invalid timing %q", label, tr.Timing))
+		}
+		switch strings.ToUpper(strings.TrimSpace(tr.Event)) {
+		case "INSERT", "UPDATE", "DELETE":
+		default:
+			errs = append(errs, fmt.Errorf("%s has invalid event %q", label, tr.Event))
+		}
+		if strings.TrimSpace(tr.Body) == "" {
+			errs = append(errs, fmt.Errorf("%s has an empty body", label))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (r Retention) validate() error {
+	var errs []error
+	if r.MaxAge < 0 {
+		errs = append(errs, fmt.Errorf("invalid max age: %s", r.MaxAge))
+	}
+	if r.MaxRows < 0 {
+		errs = append(errs, fmt.Errorf("invalid max rows: %d", r.MaxRows))
+	}
+	if r.BatchSize < 0 {
+		errs = append(errs, fmt.Errorf("invalid batch size: %d", r.BatchSize))
+	}
+	if r.MaxAge == 0 && r.MaxRows == 0 {
+		errs = append(errs, errors.New("needs a max age or a max row count"))
+	}
+	if r.MaxAge > 0 && strings.TrimSpace(r.Column) == "" {
+		errs = append(errs, errors.New("max age needs a timestamp column"))
+	}
+	return errors.Join(errs...)
+}
+
+var identifierRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func (r ConflictResolution) validate() error {
+	switch r {
+	case "", ConflictRollback, ConflictAbort, ConflictFail, ConflictIgnore, ConflictReplace:
+		return nil
+	default:
+		return fmt.Errorf("invalid conflict resolution %q", r)
+	}
+}
+
+func validateForeignKeyAction(action string) error {
+	switch strings.ToUpper(strings.Join(strings.Fields(action), " ")) {
+	case "", "SET NULL", "SET DEFAULT", "CASCADE", "RESTRICT", "NO ACTION":
+		return nil
+	default:
+		return fmt.Errorf("invalid action %q", action)
+	}
+}
+
+func constraintLabel(kind string, name string, i int) string {
+	if name != "" {
+		return fmt.Sprintf("%s %q", kind, name)
+	}
+	return fmt.Sprintf("%s #%d", kind, i+1)
+}
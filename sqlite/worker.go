@@ -0,0 +1,241 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultWorkerVisibility      = 30 * time.Second
+	defaultWorkerPollInterval    = time.Second
+	defaultWorkerShutdownTimeout = 30 * time.Second
+)
+
+type JobHandler func(ctx context.Context, job *Job) error
+
+type WorkerOptions struct {
+	Concurrency  int
+	Visibility   time.Duration
+	PollInterval time.Duration
+	// How long jobs in flight may finish once Run is stopped before their context is cancelled
+	ShutdownTimeout time.Duration
+}
+
+type Worker struct {
+	queue    *Queue
+	options  WorkerOptions
+	owner    string
+	mutex    sync.RWMutex
+	handlers map[string]JobHandler
+}
+
+func NewWorker(queue *Queue, opts WorkerOptions) (*Worker, error) {
+	if queue == nil {
+		return nil, errors.New("queue is nil")
+	}
+	if opts.Concurrency < 0 || opts.Visibility < 0 || opts.PollInterval < 0 || opts.ShutdownTimeout < 0 {
+		return nil, fmt.Errorf("invalid worker options: %+v", opts)
+	}
+	if opts.Concurrency == 0 {
+		opts.Concurrency = 1
+	}
+	if opts.Visibility == 0 {
+		opts.Visibility = defaultWorkerVisibility
+	}
+	if opts.PollInterval == 0 {
+		opts.PollInterval = defaultWorkerPollInterval
+	}
+	if opts.ShutdownTimeout == 0 {
+		opts.ShutdownTimeout = defaultWorkerShutdownTimeout
+	}
+
+	host, _ := os.Hostname()
+	return &Worker{
+		queue:    queue,
+		options:  opts,
+		owner:    fmt.Sprintf("%s-%d-%d", host, os.Getpid(), time.Now().UnixNano()),
+		handlers: map[string]JobHandler{},
+	}, nil
+}
+
+func (w *Worker) Handle(kind string, handler JobHandler) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.handlers[kind] = handler
+}
+
+// Blocks until ctx is done, then waits for the jobs in flight.
+// Their handlers keep running for up to ShutdownTimeout before their context is cancelled
+func (w *Worker) Run(ctx context.Context) error {
+	kinds := w.kinds()
+	if len(kinds) == 0 {
+		return errors.New("worker has no job handlers")
+	}
+
+	log.Infof("Job worker %s started on queue %q with %d slots", w.owner, w.queue.name, w.options.Concurrency)
+
+	slots := make(chan struct{}, w.options.Concurrency)
+	var running sync.WaitGroup
+
+	handlerCtx, cancelHandlers := context.WithCancel(context.WithoutCancel(ctx))
+	drained := make(chan struct{})
+	go w.drain(ctx, drained, cancelHandlers)
+	defer func() {
+		running.Wait()
+		close(drained)
+		cancelHandlers()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Infof("Job worker %s stopped", w.owner)
+			return nil
+		case slots <- struct{}{}:
+		}
+
+		job, err := w.queue.Lease(w.owner, w.options.Visibility, kinds...)
+		if err != nil {
+			<-slots
+			if !errors.Is(err, ErrNotFound) {
+				log.Errorf("Job worker %s can not lease a job: %v", w.owner, err)
+			}
+			select {
+			case <-ctx.Done():
+			case <-time.After(w.options.PollInterval):
+			}
+			continue
+		}
+
+		running.Add(1)
+		go func() {
+			defer running.Done()
+			defer func() { <-slots }()
+			w.process(handlerCtx, job)
+		}()
+	}
+}
+
+func (w *Worker) process(ctx context.Context, job *Job) {
+	w.mutex.RLock()
+	handler := w.handlers[job.Kind]
+	w.mutex.RUnlock()
+
+	jobCtx, cancel := context.WithCancel(ctx)
+	beating := make(chan struct{})
+	var leaseErr error
+	go func() {
+		defer close(beating)
+		leaseErr = w.heartbeat(jobCtx, cancel, job)
+	}()
+
+	err := w.call(jobCtx, handler, job)
+	cancel()
+	<-beating
+
+	// Another worker may hold the job by now, it is theirs to complete or fail
+	if errors.Is(leaseErr, ErrLeaseLost) {
+		log.Warnf("Job %d (%s) lost its lease and was cancelled: %v", job.ID, job.Kind, err)
+		return
+	}
+
+	if err == nil {
+		if err := w.queue.Complete(job); err != nil {
+			log.Errorf("Job %d (%s) can not be completed: %v", job.ID, job.Kind, err)
+		}
+		return
+	}
+
+	// A job cut short by the shutdown did not fail, it goes back without losing an attempt
+	if ctx.Err() != nil {
+		if releaseErr := w.queue.Release(job); releaseErr != nil {
+			log.Errorf("Job %d (%s) was interrupted by shutdown and can not be released: %v", job.ID, job.Kind, releaseErr)
+			return
+		}
+		log.Warnf("Job %d (%s) was interrupted by shutdown and released: %v", job.ID, job.Kind, err)
+		return
+	}
+
+	if failErr := w.queue.Fail(job, err); failErr != nil {
+		log.Errorf("Job %d (%s) failed with %v and can not be rescheduled: %v", job.ID, job.Kind, err, failErr)
+		return
+	}
+	if job.State == JobDead {
+		log.Errorf("Job %d (%s) is dead after %d attempts: %v", job.ID, job.Kind, job.Attempts, err)
+		return
+	}
+	log.Warnf("Job %d (%s) attempt %d failed, retry at %s: %v", job.ID, job.Kind, job.Attempts, job.RunAt.Format(time.DateTime), err)
+}
+
+// Cancels the handlers still running ShutdownTimeout after ctx is done
+func (w *Worker) drain(ctx context.Context, drained <-chan struct{}, cancelHandlers context.CancelFunc) {
+	select {
+	case <-ctx.Done():
+	case <-drained:
+		return
+	}
+
+	timer := time.NewTimer(w.options.ShutdownTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		log.Warnf("Job worker %s cancels the jobs still running after %s", w.owner, w.options.ShutdownTimeout)
+		cancelHandlers()
+	case <-drained:
+	}
+}
+
+func (w *Worker) call(ctx context.Context, handler JobHandler, job *Job) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("job handler panic: %v", p)
+		}
+	}()
+
+	if handler == nil {
+		return fmt.Errorf("no handler for job kind %q", job.Kind)
+	}
+	return handler(ctx, job)
+}
+
+// Keeps the lease alive while a long job runs, a lost lease cancels the handler
+func (w *Worker) heartbeat(ctx context.Context, cancel context.CancelFunc, job *Job) error {
+	ticker := time.NewTicker(w.options.Visibility / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := w.queue.Extend(job, w.options.Visibility); err != nil {
+				if errors.Is(err, ErrLeaseLost) {
+					cancel()
+				}
+				log.Warnf("Job %d (%s) lease can not be extended: %v", job.ID, job.Kind, err)
+				return err
+			}
+		}
+	}
+}
+
+func (w *Worker) kinds() []string {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+
+	kinds := make([]string, 0, len(w.handlers))
+	for kind := range w.handlers {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}